@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BatchCreater returns the values of the missing keys of GetOrCreateMany. The
+// keys left out of the map are cached as ErrNotFound for the negative ttl.
+type BatchCreater func(keys []string) (map[string]any, error)
+
+type SetOption func(*setOptions)
+
+type setOptions struct {
+	ttl  time.Duration
+	tags []string
+}
+
+// WithSetTTL sets the ttl of the values, none by default.
+func WithSetTTL(ttl time.Duration) SetOption {
+	return func(o *setOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithTags tags the values, e.g. with "user:42", so that they are deleted
+// together by InvalidateTag.
+func WithTags(tags ...string) SetOption {
+	return func(o *setOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+func newSetOptions(opts []SetOption) *setOptions {
+	o := &setOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// batchStore is implemented by the caches, with the batch operations done
+// in a round trip by RedisCache.
+type batchStore interface {
+	getPayloads(keys []string) (map[string]payload, error)
+	setPayloads(payloads map[string]payload, ttl time.Duration, tags []string) error
+}
+
+func setValues(s batchStore, values map[string]any, opts []SetOption) error {
+	o := newSetOptions(opts)
+	payloads := make(map[string]payload, len(values))
+	for key, value := range values {
+		payloads[key] = payload{Content: value}
+	}
+	return s.setPayloads(payloads, o.ttl, o.tags)
+}
+
+func getMany(s batchStore, keys []string, out any) error {
+	payloads, err := s.getPayloads(keys)
+	if err != nil {
+		return err
+	}
+	return fillMap(out, payloads)
+}
+
+// getOrCreateMany calls creator once with the missing keys, and sets out
+// to the values found or created.
+func getOrCreateMany(s batchStore, keys []string, ttl time.Duration, creator BatchCreater, out any, o *getOrCreateOptions) error {
+	payloads, err := s.getPayloads(keys)
+	if err != nil {
+		return err
+	}
+
+	var misses []string
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if _, ok := payloads[key]; !ok && !seen[key] {
+			misses = append(misses, key)
+		}
+		seen[key] = true
+	}
+	if len(misses) == 0 {
+		return fillMap(out, payloads)
+	}
+
+	values, err := creator(misses)
+	if err != nil {
+		return err
+	}
+	created := make(map[string]payload, len(values))
+	notFound := make(map[string]payload)
+	for _, key := range misses {
+		if value, ok := values[key]; ok {
+			created[key] = payload{Content: value}
+		} else if o.negativeTTL > 0 {
+			notFound[key] = payload{Error: ErrNotFound, sentinel: ErrNotFound}
+		}
+	}
+
+	hard, _ := o.ttls(ttl)
+	if err := s.setPayloads(created, hard, nil); err != nil {
+		return err
+	}
+	if err := s.setPayloads(notFound, o.negativeTTLOf(ttl), nil); err != nil {
+		return err
+	}
+	for key, p := range created {
+		payloads[key] = p
+	}
+	return fillMap(out, payloads)
+}
+
+// fillMap sets the values of payloads in the map pointed to by out, e.g. a
+// *map[string]User, leaving out the negative ones.
+func fillMap(out any, payloads map[string]payload) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Map || rv.Elem().Type().Key().Kind() != reflect.String {
+		return errors.Errorf("cache: out must point to a map of string keys, not %T", out)
+	}
+
+	m := rv.Elem()
+	if m.IsNil() {
+		m.Set(reflect.MakeMapWithSize(m.Type(), len(payloads)))
+	}
+	for key, p := range payloads {
+		if p.Error != nil {
+			continue
+		}
+		value := reflect.New(m.Type().Elem())
+		if err := p.Get(value.Interface()); err != nil {
+			return errors.Wrapf(err, "decode %s", key)
+		}
+		m.SetMapIndex(reflect.ValueOf(key).Convert(m.Type().Key()), value.Elem())
+	}
+	return nil
+}
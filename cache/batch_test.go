@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func testBatch(t *testing.T, cache CacheWithTTL) {
+	keys := []string{"batch_a", "batch_b", "batch_c"}
+	defer cache.DeleteMany(keys)
+
+	if err := cache.SetMany(map[string]any{
+		"batch_a": testData{Message: "a"},
+		"batch_b": testData{Message: "b"},
+	}, WithSetTTL(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]testData
+	if err := cache.GetMany(keys, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]testData{"batch_a": {Message: "a"}, "batch_b": {Message: "b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetMany = %v, want %v", got, want)
+	}
+
+	var requested [][]string
+	creator := func(keys []string) (map[string]any, error) {
+		sort.Strings(keys)
+		requested = append(requested, keys)
+		values := make(map[string]any)
+		for _, key := range keys {
+			if key != "batch_c" {
+				values[key] = testData{Message: "created " + key}
+			}
+		}
+		return values, nil
+	}
+	if err := cache.DeleteMany([]string{"batch_b"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		got = nil
+		if err := cache.GetOrCreateManyWithTTL(keys, time.Minute, creator, &got); err != nil {
+			t.Fatal(err)
+		}
+		want = map[string]testData{"batch_a": {Message: "a"}, "batch_b": {Message: "created batch_b"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetOrCreateMany = %v, want %v", got, want)
+		}
+	}
+	// The missing batch_c is cached as not found.
+	if !reflect.DeepEqual(requested, [][]string{{"batch_b", "batch_c"}}) {
+		t.Errorf("creator called with %v", requested)
+	}
+}
+
+func testTags(t *testing.T, cache Cache) {
+	defer cache.DeleteMany([]string{"tag_a", "tag_b", "tag_other"})
+
+	cache.Set("tag_a", "a", WithTags("user:42"))
+	cache.Set("tag_b", "b", WithTags("user:42", "team:1"), WithSetTTL(time.Minute))
+	cache.Set("tag_other", "other", WithTags("user:43"))
+	if err := cache.InvalidateTag("user:42"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]string
+	if err := cache.GetMany([]string{"tag_a", "tag_b", "tag_other"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, map[string]string{"tag_other": "other"}) {
+		t.Errorf("values after InvalidateTag = %v", got)
+	}
+
+	// A key set again without the tag is kept.
+	defer cache.Delete("tag_reset")
+	cache.Set("tag_reset", "v1", WithTags("reset"))
+	cache.Set("tag_reset", "v2")
+	if err := cache.InvalidateTag("reset"); err != nil {
+		t.Fatal(err)
+	}
+	var out string
+	if err := cache.Get("tag_reset", &out); err != nil || out != "v2" {
+		t.Errorf("key set without the tag = %q, %v", out, err)
+	}
+}
+
+func TestMemoryCacheBatch(t *testing.T) {
+	mc := NewMemoryCache(time.Minute)
+	defer mc.Close()
+	testBatch(t, mc)
+	testTags(t, mc)
+}
+
+func TestRedisCacheBatch(t *testing.T) {
+	testBatch(t, redisCacheWithTTL{c})
+	testTags(t, c)
+}
+
+func TestTieredCacheBatch(t *testing.T) {
+	a, b := newTestTieredCache(t), newTestTieredCache(t)
+	time.Sleep(50 * time.Millisecond)
+	testBatch(t, a)
+
+	testTags(t, b)
+
+	defer b.Delete("tag_a")
+	b.Set("tag_a", "a", WithTags("user:42"))
+	waitValue(t, a, "tag_a", "a")
+	if err := b.InvalidateTag("user:42"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := a.l1.lookup("tag_a"); !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("tagged key kept in the L1 of another replica")
+}
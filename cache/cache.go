@@ -7,31 +7,63 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ErrNotFound is returned on a miss, and by a creator for a value which
+// does not exist, which is cached for the negative ttl.
+var ErrNotFound = errors.New("cache: not found")
+
 type Creater func() (any, error)
 
 type Cache interface {
 	Get(key string, out any) error
-	Set(key string, value any) error
-	GetOrCreate(key string, creater Creater, out any) error
+	Set(key string, value any, opts ...SetOption) error
+	GetOrCreate(key string, creater Creater, out any, opts ...GetOrCreateOption) error
 	Delete(key string) error
+	// GetMany sets the values of the keys found in the map pointed to by
+	// out, e.g. a *map[string]User.
+	GetMany(keys []string, out any) error
+	SetMany(values map[string]any, opts ...SetOption) error
+	DeleteMany(keys []string) error
+	// GetOrCreateMany is GetMany, calling creator once with the missing keys.
+	GetOrCreateMany(keys []string, creator BatchCreater, out any, opts ...GetOrCreateOption) error
+	// InvalidateTag deletes the values set with the tag.
+	InvalidateTag(tag string) error
 	Close() error
 }
 
 type CacheWithTTL interface {
 	Cache
-	GetOrCreateWithTTL(key string, ttl time.Duration, creator Creater, out any) error
+	GetOrCreateWithTTL(key string, ttl time.Duration, creator Creater, out any, opts ...GetOrCreateOption) error
+	GetOrCreateManyWithTTL(keys []string, ttl time.Duration, creator BatchCreater, out any, opts ...GetOrCreateOption) error
 	SetWithTTL(key string, ttl time.Duration, value any) error
 }
 
 type payload struct {
 	Content any   `json:"content"`
 	Error   error `json:"error,omitempty"`
+	// sentinel is the cacheable error matched by Error.
+	sentinel error
+	// RefreshAt is the unix milli after which the value is refreshed in the
+	// background by GetOrCreate, 0 for never.
+	RefreshAt int64 `json:"refresh_at,omitempty"`
+
+	// raw is the content of a payload read from redis, decoded by codec.
+	raw   []byte
+	codec Codec
+	// expireAt is when a payload read from redis expires there, zero for
+	// never or unknown.
+	expireAt time.Time
 }
 
 func (p payload) Get(out any) error {
 	if p.Error != nil {
 		return p.Error
 	}
+	if p.codec != nil {
+		if len(p.raw) == 0 {
+			return nil
+		}
+		return errors.Wrap(p.codec.Unmarshal(p.raw, out), "decode")
+	}
 
 	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 		Result:  out,
@@ -44,10 +76,17 @@ func (p payload) Get(out any) error {
 	return decoder.Decode(p.Content)
 }
 
-func newPayload(content any, err error) payload {
-	if err != nil {
-		return payload{Error: err}
-	}
+// cachedError is an error read from redis, matching with errors.Is the
+// cacheable error whose message is sentinel.
+type cachedError struct {
+	msg      string
+	sentinel string
+}
+
+func (e *cachedError) Error() string {
+	return e.msg
+}
 
-	return payload{Content: content}
+func (e *cachedError) Is(target error) bool {
+	return e.sentinel != "" && target.Error() == e.sentinel
 }
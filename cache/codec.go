@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/superwhys/goutils/redisutils"
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// Codec encodes the values stored by RedisCache.
+type Codec = redisutils.Codec
+
+// JSONCodec is the default codec.
+type JSONCodec = redisutils.JSONCodec
+
+// envelope is a payload as stored by RedisCache, with its content encoded by
+// the codec named in the header of the value.
+type envelope struct {
+	Content   []byte `msgpack:"c,omitempty"`
+	Error     string `msgpack:"e,omitempty"`
+	Sentinel  string `msgpack:"s,omitempty"`
+	RefreshAt int64  `msgpack:"r,omitempty"`
+	// ExpireAt is the unix milli at which the value expires, 0 for never.
+	ExpireAt int64 `msgpack:"x,omitempty"`
+}
+
+// legacyPayload is a payload as stored in JSON before the codecs.
+type legacyPayload struct {
+	Content   json.RawMessage `json:"content"`
+	Error     json.RawMessage `json:"error"`
+	RefreshAt int64           `json:"refresh_at"`
+}
+
+func (c *RedisCache) encode(p payload, ttl time.Duration) ([]byte, error) {
+	e := envelope{RefreshAt: p.RefreshAt}
+	if ttl > 0 {
+		e.ExpireAt = time.Now().Add(ttl).UnixMilli()
+	}
+	if p.Error != nil {
+		e.Error = p.Error.Error()
+		if p.sentinel != nil {
+			e.Sentinel = p.sentinel.Error()
+		}
+	} else if p.Content != nil {
+		content, err := c.codec.Marshal(p.Content)
+		if err != nil {
+			return nil, err
+		}
+		e.Content = content
+	}
+
+	data, err := msgpack.Marshal(e)
+	if err != nil {
+		return nil, errors.Wrap(err, "msgpack.Marshal")
+	}
+	return redisutils.Frame(c.codec, c.compression, c.minCompressSize, data)
+}
+
+// decode returns the payload of data, whose content is decoded by Get.
+func (c *RedisCache) decode(data []byte) (payload, error) {
+	codec, body, ok, err := redisutils.Unframe(data)
+	if err != nil {
+		return payload{}, err
+	}
+	if !ok {
+		return decodeLegacy(body)
+	}
+
+	var e envelope
+	if err := msgpack.Unmarshal(body, &e); err != nil {
+		return payload{}, errors.Wrap(err, "msgpack.Unmarshal")
+	}
+	p := payload{RefreshAt: e.RefreshAt, raw: e.Content, codec: codec}
+	if e.ExpireAt > 0 {
+		p.expireAt = time.UnixMilli(e.ExpireAt)
+	}
+	if e.Error != "" {
+		p.Error = &cachedError{msg: e.Error, sentinel: e.Sentinel}
+	}
+	return p, nil
+}
+
+func decodeLegacy(data []byte) (payload, error) {
+	var lp legacyPayload
+	if err := json.Unmarshal(data, &lp); err != nil {
+		return payload{}, err
+	}
+
+	p := payload{RefreshAt: lp.RefreshAt, codec: JSONCodec{}}
+	if len(lp.Error) > 0 && string(lp.Error) != "null" {
+		// The errors were stored as the JSON of their fields, most often {}.
+		p.Error = &cachedError{msg: "cached error " + string(lp.Error)}
+		return p, nil
+	}
+	if len(lp.Content) > 0 && string(lp.Content) != "null" {
+		p.raw = lp.Content
+	}
+	return p, nil
+}
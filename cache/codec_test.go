@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/superwhys/goutils/dialer"
+	"github.com/superwhys/goutils/redisutils"
+)
+
+func TestRedisCacheLegacyPayload(t *testing.T) {
+	defer c.Delete("codec_legacy_key")
+	if _, err := c.Do("SET", "codec_legacy_key", `{"content":{"message":"legacy"}}`); err != nil {
+		t.Fatal(err)
+	}
+
+	var out testData
+	if err := c.Get("codec_legacy_key", &out); err != nil || out.Message != "legacy" {
+		t.Errorf("legacy value = %+v, %v", out, err)
+	}
+}
+
+func TestRedisCacheCodecMigration(t *testing.T) {
+	pool := dialer.DialRedisPool("localhost:6379", 2, 100)
+	msgpackCache := NewRedisCache(pool, WithCodec(redisutils.MsgpackCodec{}))
+	gobCache := NewRedisCache(pool, WithCodec(redisutils.GobCodec{}), WithCompression(redisutils.Gzip, 64))
+	defer c.Delete("codec_json_key")
+	defer c.Delete("codec_gob_key")
+
+	if err := c.Set("codec_json_key", testData{Message: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	long := testData{Message: strings.Repeat("gob", 100)}
+	if err := gobCache.Set("codec_gob_key", long); err != nil {
+		t.Fatal(err)
+	}
+
+	var out testData
+	if err := msgpackCache.Get("codec_json_key", &out); err != nil || out.Message != "json" {
+		t.Errorf("json value read by msgpack cache = %+v, %v", out, err)
+	}
+	if err := msgpackCache.Get("codec_gob_key", &out); err != nil || out != long {
+		t.Errorf("gob value read by msgpack cache = %+v, %v", out, err)
+	}
+
+	data, _ := gobCache.getBytes("codec_gob_key")
+	if len(data) >= len(long.Message) {
+		t.Errorf("value of %d bytes not compressed", len(data))
+	}
+}
+
+func TestRedisCacheErrorPayload(t *testing.T) {
+	defer c.Delete("codec_error_key")
+	errFailed := errors.New("creator failed")
+	creator := func() (any, error) {
+		return nil, errFailed
+	}
+	var out string
+	if err := c.GetOrCreateWithTTL("codec_error_key", time.Minute, creator, &out, WithCacheableErrors(errFailed)); err == nil {
+		t.Fatal("creator error not returned")
+	}
+
+	other := NewRedisCache(dialer.DialRedisPool("localhost:6379", 2, 100), WithCodec(redisutils.MsgpackCodec{}))
+	if err := other.Get("codec_error_key", &out); !errors.Is(err, errFailed) || err.Error() != "creator failed" {
+		t.Errorf("cached error = %v", err)
+	}
+}
+
+func TestTypedCodecs(t *testing.T) {
+	pool := dialer.DialRedisPool("localhost:6379", 2, 100)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+	for _, codec := range []Codec{redisutils.MsgpackCodec{}, redisutils.GobCodec{}} {
+		rc := NewRedisCache(pool, WithPrefix("codec"), WithCodec(codec), WithCompression(redisutils.Snappy, 0))
+		times := NewTyped[time.Time](rc)
+		if err := times.Set(ctx, "time", now); err != nil {
+			t.Fatalf("%T: %v", codec, err)
+		}
+		got, ok, err := times.Get(ctx, "time")
+		if err != nil || !ok || !got.Equal(now) {
+			t.Errorf("%T: Get = %v, %v, %v", codec, got, ok, err)
+		}
+		rc.Delete("time")
+	}
+}
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+	"reflect"
+)
+
+// EvictionPolicy chooses the entry evicted when a MemoryCache is full.
+type EvictionPolicy int
+
+const (
+	// PolicyLRU evicts the least recently used entry.
+	PolicyLRU EvictionPolicy = iota
+	// PolicyLFU evicts the least frequently used entry, the least recently
+	// used one among the ones used as often.
+	PolicyLFU
+)
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case PolicyLRU:
+		return "lru"
+	case PolicyLFU:
+		return "lfu"
+	default:
+		return fmt.Sprintf("EvictionPolicy(%d)", int(p))
+	}
+}
+
+// EvictReason tells OnEvict why an entry left the cache.
+type EvictReason int
+
+const (
+	// EvictedCapacity is an entry evicted by the policy to make room.
+	EvictedCapacity EvictReason = iota
+	// EvictedExpired is an entry whose ttl is over.
+	EvictedExpired
+	// EvictedDeleted is an entry removed by Delete.
+	EvictedDeleted
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictedCapacity:
+		return "capacity"
+	case EvictedExpired:
+		return "expired"
+	case EvictedDeleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("EvictReason(%d)", int(r))
+	}
+}
+
+// evictionList orders the entries of a shard by the policy.
+type evictionList interface {
+	add(e *memoryEntry)
+	touch(e *memoryEntry)
+	remove(e *memoryEntry)
+	// victim returns the entry to evict, nil if there is none.
+	victim() *memoryEntry
+}
+
+func newEvictionList(policy EvictionPolicy) evictionList {
+	if policy == PolicyLFU {
+		return &lfuList{}
+	}
+	return &lruList{l: list.New()}
+}
+
+type lruList struct {
+	l *list.List
+}
+
+func (l *lruList) add(e *memoryEntry) {
+	e.elem = l.l.PushFront(e)
+}
+
+func (l *lruList) touch(e *memoryEntry) {
+	l.l.MoveToFront(e.elem)
+}
+
+func (l *lruList) remove(e *memoryEntry) {
+	l.l.Remove(e.elem)
+	e.elem = nil
+}
+
+func (l *lruList) victim() *memoryEntry {
+	back := l.l.Back()
+	if back == nil {
+		return nil
+	}
+	return back.Value.(*memoryEntry)
+}
+
+// lfuList is a min heap of the entries by use count, then by last use.
+type lfuList struct {
+	entries []*memoryEntry
+	tick    uint64
+}
+
+func (l *lfuList) Len() int { return len(l.entries) }
+
+func (l *lfuList) Less(i, j int) bool {
+	a, b := l.entries[i], l.entries[j]
+	if a.uses != b.uses {
+		return a.uses < b.uses
+	}
+	return a.lastUse < b.lastUse
+}
+
+func (l *lfuList) Swap(i, j int) {
+	l.entries[i], l.entries[j] = l.entries[j], l.entries[i]
+	l.entries[i].index = i
+	l.entries[j].index = j
+}
+
+func (l *lfuList) Push(x any) {
+	e := x.(*memoryEntry)
+	e.index = len(l.entries)
+	l.entries = append(l.entries, e)
+}
+
+func (l *lfuList) Pop() any {
+	n := len(l.entries)
+	e := l.entries[n-1]
+	l.entries[n-1] = nil
+	l.entries = l.entries[:n-1]
+	e.index = -1
+	return e
+}
+
+func (l *lfuList) add(e *memoryEntry) {
+	l.tick++
+	e.uses, e.lastUse = 1, l.tick
+	heap.Push(l, e)
+}
+
+func (l *lfuList) touch(e *memoryEntry) {
+	l.tick++
+	e.uses++
+	e.lastUse = l.tick
+	heap.Fix(l, e.index)
+}
+
+func (l *lfuList) remove(e *memoryEntry) {
+	heap.Remove(l, e.index)
+}
+
+func (l *lfuList) victim() *memoryEntry {
+	if len(l.entries) == 0 {
+		return nil
+	}
+	return l.entries[0]
+}
+
+// entryOverhead is the estimated size of an entry besides its key and value.
+const entryOverhead = 96
+
+// EstimateSize is the default sizer of MemoryCache, a rough count of the bytes
+// referenced by v, following pointers, slices, maps and struct fields.
+func EstimateSize(v any) int64 {
+	if v == nil {
+		return 0
+	}
+	return estimateSize(reflect.ValueOf(v), 0)
+}
+
+func estimateSize(v reflect.Value, depth int) int64 {
+	if depth > 8 {
+		return 0
+	}
+
+	size := int64(v.Type().Size())
+	switch v.Kind() {
+	case reflect.String:
+		size += int64(v.Len())
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			size += estimateSize(v.Elem(), depth+1)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			size += int64(v.Len())
+			break
+		}
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			size += estimateSize(v.Index(i), depth+1)
+		}
+		if v.Kind() == reflect.Array {
+			size -= int64(v.Type().Size())
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			size += estimateSize(iter.Key(), depth+1) + estimateSize(iter.Value(), depth+1)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			size += estimateSize(v.Field(i), depth+1) - int64(v.Field(i).Type().Size())
+		}
+	}
+	return size
+}
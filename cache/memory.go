@@ -1,10 +1,15 @@
 package cache
 
 import (
+	"container/list"
 	"context"
-	"errors"
+	"hash/maphash"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/superwhys/goutils/lg"
+	"golang.org/x/sync/singleflight"
 )
 
 var _ CacheWithTTL = (*MemoryCache)(nil)
@@ -30,18 +35,131 @@ func (p *payloadWithExpire) Get(out any) error {
 	return p.payload.Get(out)
 }
 
+type memoryEntry struct {
+	payloadWithExpire
+	key  string
+	size int64
+	tags []string
+
+	// elem is the element of the entry in the lru list.
+	elem *list.Element
+	// index, uses and lastUse place the entry in the lfu heap.
+	index   int
+	uses    uint64
+	lastUse uint64
+}
+
+type memoryShard struct {
+	lock     sync.Mutex
+	entries  map[string]*memoryEntry
+	eviction evictionList
+	bytes    int64
+}
+
+// MemoryCacheStats are the counters of a MemoryCache.
+type MemoryCacheStats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+	Entries     int
+	Bytes       int64
+}
+
 type MemoryCache struct {
-	lock             sync.RWMutex
-	payload          map[string]payloadWithExpire
+	shards           []*memoryShard
+	seed             maphash.Seed
+	maxEntries       int
+	maxBytes         int64
+	policy           EvictionPolicy
+	sizer            func(value any) int64
+	onEvict          func(key string, value any, reason EvictReason)
 	cancel           func()
 	rotationInterval time.Duration
+	fills            singleflight.Group
+	refreshes        refresher
+
+	// tags are the keys by tag, locked after the shards.
+	tagsMu sync.Mutex
+	tags   map[string]map[string]struct{}
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+type MemoryCacheOption func(*MemoryCache)
+
+// WithMaxEntries bounds the count of entries, unbounded by default.
+func WithMaxEntries(n int) MemoryCacheOption {
+	return func(mc *MemoryCache) {
+		mc.maxEntries = n
+	}
+}
+
+// WithMaxBytes bounds the estimated size of the keys and values, see WithSizer.
+func WithMaxBytes(n int64) MemoryCacheOption {
+	return func(mc *MemoryCache) {
+		mc.maxBytes = n
+	}
+}
+
+// WithEvictionPolicy sets the policy of the entries evicted when the cache
+// is full, PolicyLRU by default.
+func WithEvictionPolicy(policy EvictionPolicy) MemoryCacheOption {
+	return func(mc *MemoryCache) {
+		mc.policy = policy
+	}
+}
+
+// WithShards sets the count of shards, each one having its own lock, 16 by
+// default. The limits are split among the shards, so a shard may evict before
+// the whole cache is full.
+func WithShards(n int) MemoryCacheOption {
+	return func(mc *MemoryCache) {
+		if n > 0 {
+			mc.shards = make([]*memoryShard, n)
+		}
+	}
 }
 
-func NewMemoryCache(rotationInterval time.Duration) *MemoryCache {
+// WithSizer sets the size estimate of the values used by WithMaxBytes,
+// EstimateSize by default.
+func WithSizer(sizer func(value any) int64) MemoryCacheOption {
+	return func(mc *MemoryCache) {
+		mc.sizer = sizer
+	}
+}
+
+// WithOnEvict sets a callback called with the entries leaving the cache,
+// outside of the locks of the cache.
+func WithOnEvict(fn func(key string, value any, reason EvictReason)) MemoryCacheOption {
+	return func(mc *MemoryCache) {
+		mc.onEvict = fn
+	}
+}
+
+// NewMemoryCache returns a cache whose expired entries are removed at every
+// rotationInterval, and unbounded unless limited by the options.
+func NewMemoryCache(rotationInterval time.Duration, opts ...MemoryCacheOption) *MemoryCache {
 	mc := &MemoryCache{
-		payload:          make(map[string]payloadWithExpire),
+		shards:           make([]*memoryShard, 16),
+		seed:             maphash.MakeSeed(),
+		sizer:            EstimateSize,
 		rotationInterval: rotationInterval,
+		tags:             make(map[string]map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(mc)
+	}
+	for i := range mc.shards {
+		mc.shards[i] = &memoryShard{
+			entries:  make(map[string]*memoryEntry),
+			eviction: newEvictionList(mc.policy),
+		}
 	}
+
 	ctx, cancel := context.WithCancel(context.TODO())
 	mc.cancel = cancel
 	go mc.runRotation(ctx, rotationInterval)
@@ -52,99 +170,356 @@ func (mc *MemoryCache) runRotation(ctx context.Context, rotationInterval time.Du
 	ticker := time.NewTicker(rotationInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		if ctx.Err() != nil {
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
 		}
-		mc.lock.Lock()
-		for key, value := range mc.payload {
-			if value.IsExpire() {
-				delete(mc.payload, key)
+		for _, shard := range mc.shards {
+			var expired []*memoryEntry
+			shard.lock.Lock()
+			for _, e := range shard.entries {
+				if e.IsExpire() {
+					mc.removeLocked(shard, e)
+					expired = append(expired, e)
+				}
 			}
+			shard.lock.Unlock()
+			mc.evicted(expired, EvictedExpired)
 		}
-		mc.lock.Unlock()
 	}
 }
 
-func (mc *MemoryCache) Get(key string, out any) error {
-	mc.lock.RLock()
-	p, ok := mc.payload[key]
-	mc.lock.RUnlock()
+func (mc *MemoryCache) shard(key string) *memoryShard {
+	return mc.shards[maphash.String(mc.seed, key)%uint64(len(mc.shards))]
+}
+
+// limits returns the limits of a shard.
+func (mc *MemoryCache) limits() (entries int, bytes int64) {
+	n := len(mc.shards)
+	if mc.maxEntries > 0 {
+		entries = (mc.maxEntries + n - 1) / n
+	}
+	if mc.maxBytes > 0 {
+		bytes = (mc.maxBytes + int64(n) - 1) / int64(n)
+	}
+	return
+}
+
+func (mc *MemoryCache) removeLocked(shard *memoryShard, e *memoryEntry) {
+	delete(shard.entries, e.key)
+	shard.eviction.remove(e)
+	shard.bytes -= e.size
+	if len(e.tags) > 0 {
+		mc.tagsMu.Lock()
+		for _, tag := range e.tags {
+			delete(mc.tags[tag], e.key)
+			if len(mc.tags[tag]) == 0 {
+				delete(mc.tags, tag)
+			}
+		}
+		mc.tagsMu.Unlock()
+	}
+}
+
+func (mc *MemoryCache) evicted(entries []*memoryEntry, reason EvictReason) {
+	switch reason {
+	case EvictedCapacity:
+		mc.evictions.Add(uint64(len(entries)))
+	case EvictedExpired:
+		mc.expirations.Add(uint64(len(entries)))
+	}
+	if mc.onEvict == nil {
+		return
+	}
+	for _, e := range entries {
+		mc.onEvict(e.key, e.payload.Content, reason)
+	}
+}
+
+// lookup returns the live entry of key, counting the hit or the miss.
+func (mc *MemoryCache) lookup(key string) (payload, bool) {
+	p, ok := mc.peek(key)
+	if ok {
+		mc.hits.Add(1)
+	} else {
+		mc.misses.Add(1)
+	}
+	return p, ok
+}
+
+// peek is lookup without counting, e.g. to check again a counted miss.
+func (mc *MemoryCache) peek(key string) (payload, bool) {
+	shard := mc.shard(key)
+	shard.lock.Lock()
+	e, ok := shard.entries[key]
+	if ok && e.IsExpire() {
+		mc.removeLocked(shard, e)
+		shard.lock.Unlock()
+		mc.evicted([]*memoryEntry{e}, EvictedExpired)
+		return payload{}, false
+	}
+	if !ok {
+		shard.lock.Unlock()
+		return payload{}, false
+	}
+	shard.eviction.touch(e)
+	p := e.payload
+	shard.lock.Unlock()
+	return p, true
+}
+
+func (mc *MemoryCache) store(key string, ttl time.Duration, p payload, tags ...string) {
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl)
+	}
+	e := &memoryEntry{
+		payloadWithExpire: payloadWithExpire{payload: p, expireAt: expireAt},
+		key:               key,
+		tags:              tags,
+	}
+	if mc.maxBytes > 0 {
+		e.size = int64(len(key)) + entryOverhead + mc.sizer(p.Content)
+	}
+
+	maxEntries, maxBytes := mc.limits()
+	shard := mc.shard(key)
+	var evicted []*memoryEntry
+
+	shard.lock.Lock()
+	if old, ok := shard.entries[key]; ok {
+		mc.removeLocked(shard, old)
+	}
+	// The room is made before adding e, which would be the first victim of
+	// PolicyLFU. An entry larger than the shard is kept alone.
+	for (maxEntries > 0 && len(shard.entries)+1 > maxEntries) || (maxBytes > 0 && shard.bytes+e.size > maxBytes) {
+		victim := shard.eviction.victim()
+		if victim == nil {
+			break
+		}
+		mc.removeLocked(shard, victim)
+		evicted = append(evicted, victim)
+	}
+	shard.entries[key] = e
+	shard.eviction.add(e)
+	shard.bytes += e.size
+	if len(tags) > 0 {
+		mc.tagsMu.Lock()
+		for _, tag := range tags {
+			if mc.tags[tag] == nil {
+				mc.tags[tag] = make(map[string]struct{})
+			}
+			mc.tags[tag][key] = struct{}{}
+		}
+		mc.tagsMu.Unlock()
+	}
+	shard.lock.Unlock()
+
+	mc.evicted(evicted, EvictedCapacity)
+}
 
-	if !ok || !p.IsExpire() {
-		return errors.New("not found")
+func (mc *MemoryCache) Get(key string, out any) error {
+	p, ok := mc.lookup(key)
+	if !ok {
+		return ErrNotFound
 	}
 
 	return p.Get(out)
 }
 
-func (mc *MemoryCache) Set(key string, value any) error {
-	return mc.SetWithTTL(key, 0, value)
+// getValue returns the value of key as it was set, without decoding.
+func (mc *MemoryCache) getValue(key string) (any, bool, error) {
+	p, ok := mc.lookup(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if p.Error != nil {
+		return nil, false, p.Error
+	}
+	return p.Content, true, nil
+}
+
+func (mc *MemoryCache) setValue(key string, value any, ttl time.Duration) error {
+	return mc.SetWithTTL(key, ttl, value)
 }
 
-func (mc *MemoryCache) GetOrCreate(key string, creater Creater, out any) error {
-	return mc.GetOrCreateWithTTL(key, 0, creater, out)
+func (mc *MemoryCache) Set(key string, value any, opts ...SetOption) error {
+	o := newSetOptions(opts)
+	mc.store(key, o.ttl, payload{Content: value}, o.tags...)
+	return nil
+}
+
+func (mc *MemoryCache) GetOrCreate(key string, creater Creater, out any, opts ...GetOrCreateOption) error {
+	return mc.GetOrCreateWithTTL(key, 0, creater, out, opts...)
 }
 
 func (mc *MemoryCache) Delete(key string) error {
-	mc.lock.Lock()
-	_, ok := mc.payload[key]
+	shard := mc.shard(key)
+	shard.lock.Lock()
+	e, ok := shard.entries[key]
+	if ok {
+		mc.removeLocked(shard, e)
+	}
+	shard.lock.Unlock()
+
 	if ok {
-		delete(mc.payload, key)
+		mc.evicted([]*memoryEntry{e}, EvictedDeleted)
 	}
-	mc.lock.Unlock()
 	return nil
 }
 
+// Stats returns the counters of the cache.
+func (mc *MemoryCache) Stats() MemoryCacheStats {
+	stats := MemoryCacheStats{
+		Hits:        mc.hits.Load(),
+		Misses:      mc.misses.Load(),
+		Evictions:   mc.evictions.Load(),
+		Expirations: mc.expirations.Load(),
+	}
+	for _, shard := range mc.shards {
+		shard.lock.Lock()
+		stats.Entries += len(shard.entries)
+		stats.Bytes += shard.bytes
+		shard.lock.Unlock()
+	}
+	return stats
+}
+
 func (mc *MemoryCache) Close() error {
 	mc.cancel()
-	mc.lock.Lock()
-	mc.payload = nil
-	mc.lock.Unlock()
+	mc.purge()
 	return nil
 }
 
-func (mc *MemoryCache) GetOrCreateWithTTL(key string, ttl time.Duration, creator Creater, out any) error {
-	mc.lock.RLock()
-	p, ok := mc.payload[key]
-	mc.lock.RUnlock()
+// purge removes all the entries, without calling OnEvict.
+func (mc *MemoryCache) purge() {
+	for _, shard := range mc.shards {
+		shard.lock.Lock()
+		shard.entries = make(map[string]*memoryEntry)
+		shard.eviction = newEvictionList(mc.policy)
+		shard.bytes = 0
+		shard.lock.Unlock()
+	}
+	mc.tagsMu.Lock()
+	mc.tags = make(map[string]map[string]struct{})
+	mc.tagsMu.Unlock()
+}
 
-	if ok && !p.IsExpire() {
+func (mc *MemoryCache) GetOrCreateWithTTL(key string, ttl time.Duration, creator Creater, out any, opts ...GetOrCreateOption) error {
+	o := newGetOrCreateOptions(opts)
+	if p, ok := mc.lookup(key); ok {
+		if p.needsRefresh() {
+			mc.refreshes.run(key, func() {
+				mc.refresh(key, ttl, creator, o)
+			})
+		}
 		return p.Get(out)
 	}
 
-	np := newPayload(creator())
-	mc.lock.Lock()
-
-	var expireAt time.Time
-	if ttl > 0 {
-		expireAt = time.Now().Add(ttl)
+	// The concurrent misses of key call the creator once.
+	v, err, _ := mc.fills.Do(key, func() (any, error) {
+		if p, ok := mc.peek(key); ok {
+			return p, nil
+		}
+		p, pttl, err := o.create(creator, ttl)
+		if err != nil {
+			return nil, err
+		}
+		mc.store(key, pttl, p)
+		return p, nil
+	})
+	if err != nil {
+		return err
 	}
+	return v.(payload).Get(out)
+}
 
-	mc.payload[key] = payloadWithExpire{
-		payload:  np,
-		expireAt: expireAt,
+// refresh replaces the value of key, which is kept if the creator fails.
+func (mc *MemoryCache) refresh(key string, ttl time.Duration, creator Creater, o *getOrCreateOptions) {
+	value, err := creator()
+	if err != nil {
+		lg.Warnf("cache: refresh %s: %v", key, err)
+		return
 	}
-	mc.lock.Unlock()
-	return np.Get(out)
+	hard, refreshAfter := o.ttls(ttl)
+	p := payload{Content: value}
+	stamp(&p, refreshAfter)
+	mc.store(key, hard, p)
 }
 
 func (mc *MemoryCache) SetWithTTL(key string, ttl time.Duration, value any) error {
-	p := payload{Content: value}
+	mc.store(key, ttl, payload{Content: value})
+	return nil
+}
 
-	mc.lock.Lock()
+func (mc *MemoryCache) GetMany(keys []string, out any) error {
+	return getMany(mc, keys, out)
+}
 
-	var expireAt time.Time
-	if ttl > 0 {
-		expireAt = time.Now().Add(ttl)
+func (mc *MemoryCache) SetMany(values map[string]any, opts ...SetOption) error {
+	return setValues(mc, values, opts)
+}
+
+func (mc *MemoryCache) DeleteMany(keys []string) error {
+	for _, key := range keys {
+		mc.Delete(key)
+	}
+	return nil
+}
+
+func (mc *MemoryCache) GetOrCreateMany(keys []string, creator BatchCreater, out any, opts ...GetOrCreateOption) error {
+	return mc.GetOrCreateManyWithTTL(keys, 0, creator, out, opts...)
+}
+
+func (mc *MemoryCache) GetOrCreateManyWithTTL(keys []string, ttl time.Duration, creator BatchCreater, out any, opts ...GetOrCreateOption) error {
+	return getOrCreateMany(mc, keys, ttl, creator, out, newGetOrCreateOptions(opts))
+}
+
+func (mc *MemoryCache) InvalidateTag(tag string) error {
+	mc.tagsMu.Lock()
+	keys := mc.tags[tag]
+	delete(mc.tags, tag)
+	mc.tagsMu.Unlock()
+
+	// The keys set again without the tag meanwhile are kept.
+	var deleted []*memoryEntry
+	for key := range keys {
+		shard := mc.shard(key)
+		shard.lock.Lock()
+		if e, ok := shard.entries[key]; ok && hasTag(e.tags, tag) {
+			mc.removeLocked(shard, e)
+			deleted = append(deleted, e)
+		}
+		shard.lock.Unlock()
 	}
+	mc.evicted(deleted, EvictedDeleted)
+	return nil
+}
 
-	mc.payload[key] = payloadWithExpire{
-		payload:  p,
-		expireAt: expireAt,
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
 	}
+	return false
+}
 
-	mc.lock.Unlock()
+func (mc *MemoryCache) getPayloads(keys []string) (map[string]payload, error) {
+	payloads := make(map[string]payload, len(keys))
+	for _, key := range keys {
+		if p, ok := mc.lookup(key); ok {
+			payloads[key] = p
+		}
+	}
+	return payloads, nil
+}
 
+func (mc *MemoryCache) setPayloads(payloads map[string]payload, ttl time.Duration, tags []string) error {
+	for key, p := range payloads {
+		mc.store(key, ttl, p, tags...)
+	}
 	return nil
 }
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func memoryKeys(mc *MemoryCache, keys ...string) string {
+	var found []string
+	for _, key := range keys {
+		if _, ok, _ := mc.getValue(key); ok {
+			found = append(found, key)
+		}
+	}
+	return strings.Join(found, ",")
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy EvictionPolicy
+		want   string
+	}{
+		{"lru", PolicyLRU, "a,c,d"},
+		{"lfu", PolicyLFU, "a,b,d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var evicted []string
+			mc := NewMemoryCache(time.Minute, WithShards(1), WithMaxEntries(3), WithEvictionPolicy(tt.policy),
+				WithOnEvict(func(key string, _ any, reason EvictReason) {
+					evicted = append(evicted, key+":"+reason.String())
+				}))
+			defer mc.Close()
+
+			mc.Set("a", 1)
+			mc.Set("b", 2)
+			mc.Set("c", 3)
+			// a is used the most, b more than c but before it.
+			for i := 0; i < 3; i++ {
+				mc.getValue("a")
+			}
+			mc.getValue("b")
+			mc.getValue("b")
+			mc.getValue("c")
+			mc.getValue("a")
+			mc.Set("d", 4)
+
+			if got := memoryKeys(mc, "a", "b", "c", "d"); got != tt.want {
+				t.Errorf("keys = %s, want %s", got, tt.want)
+			}
+			if len(evicted) != 1 || !strings.HasSuffix(evicted[0], ":capacity") {
+				t.Errorf("evicted = %v", evicted)
+			}
+			if stats := mc.Stats(); stats.Evictions != 1 || stats.Entries != 3 {
+				t.Errorf("stats = %+v", stats)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheMaxBytes(t *testing.T) {
+	mc := NewMemoryCache(time.Minute, WithShards(1), WithMaxBytes(1000),
+		WithSizer(func(value any) int64 { return int64(len(value.(string))) }))
+	defer mc.Close()
+
+	value := strings.Repeat("x", 300)
+	for i := 0; i < 5; i++ {
+		mc.Set(fmt.Sprintf("key%d", i), value)
+	}
+	stats := mc.Stats()
+	if stats.Entries != 2 || stats.Bytes > 1000 || stats.Evictions != 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if got := memoryKeys(mc, "key3", "key4"); got != "key3,key4" {
+		t.Errorf("keys = %s", got)
+	}
+
+	mc.Set("huge", strings.Repeat("x", 2000))
+	if got := memoryKeys(mc, "key4", "huge"); got != "huge" {
+		t.Errorf("an entry larger than the cache should be kept alone, keys = %s", got)
+	}
+}
+
+func TestMemoryCacheExpire(t *testing.T) {
+	var reasons []EvictReason
+	mc := NewMemoryCache(time.Minute, WithOnEvict(func(_ string, _ any, reason EvictReason) {
+		reasons = append(reasons, reason)
+	}))
+	defer mc.Close()
+
+	mc.SetWithTTL("short", time.Millisecond, "value")
+	mc.Set("long", "value")
+	time.Sleep(5 * time.Millisecond)
+
+	var out string
+	if err := mc.Get("short", &out); err == nil {
+		t.Errorf("expired key found")
+	}
+	if err := mc.Get("long", &out); err != nil || out != "value" {
+		t.Errorf("Get = %q, %v", out, err)
+	}
+	mc.Delete("long")
+
+	stats := mc.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Expirations != 1 || stats.Entries != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if len(reasons) != 2 || reasons[0] != EvictedExpired || reasons[1] != EvictedDeleted {
+		t.Errorf("reasons = %v", reasons)
+	}
+}
+
+func TestMemoryCacheGetOrCreateStats(t *testing.T) {
+	mc := NewMemoryCache(time.Minute)
+	defer mc.Close()
+
+	for i := 0; i < 2; i++ {
+		var out string
+		if err := mc.GetOrCreate("key", func() (any, error) { return "value", nil }, &out); err != nil || out != "value" {
+			t.Fatalf("GetOrCreate = %q, %v", out, err)
+		}
+	}
+	if stats := mc.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 1 hit and 1 miss", stats)
+	}
+}
+
+func TestMemoryCacheConcurrent(t *testing.T) {
+	mc := NewMemoryCache(time.Millisecond, WithMaxEntries(100), WithEvictionPolicy(PolicyLFU))
+	defer mc.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("key%d", (g*i)%300)
+				if _, ok, _ := mc.getValue(key); !ok {
+					mc.SetWithTTL(key, time.Millisecond, i)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := mc.Stats().Entries; n > 100+16 {
+		t.Errorf("%d entries over the limit", n)
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	type nested struct {
+		Name  string
+		Items []string
+		Ptr   *nested
+	}
+	small := EstimateSize(nested{Name: "a"})
+	large := EstimateSize(&nested{Name: strings.Repeat("a", 100), Items: []string{"b", "c"}, Ptr: &nested{}})
+	if small <= 0 || large < small+100 {
+		t.Errorf("sizes = %d, %d", small, large)
+	}
+}
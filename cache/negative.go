@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultNegativeTTL = 10 * time.Second
+
+// WithNegativeTTL sets the ttl of the negative results of the creator,
+// ErrNotFound and the cacheable errors, 10 seconds by default and never
+// longer than the ttl of the values. A ttl of 0 does not cache them.
+func WithNegativeTTL(ttl time.Duration) GetOrCreateOption {
+	return func(o *getOrCreateOptions) {
+		o.negativeTTL = ttl
+	}
+}
+
+// WithCacheableErrors caches the errors of the creator matching one of errs
+// with errors.Is, as ErrNotFound is. The other errors are returned without
+// being cached.
+func WithCacheableErrors(errs ...error) GetOrCreateOption {
+	return func(o *getOrCreateOptions) {
+		o.cacheable = append(o.cacheable, errs...)
+	}
+}
+
+// sentinel returns the cacheable error matched by err, nil for none.
+func (o *getOrCreateOptions) sentinel(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return ErrNotFound
+	}
+	for _, target := range o.cacheable {
+		if errors.Is(err, target) {
+			return target
+		}
+	}
+	return nil
+}
+
+// create calls creator and returns the payload to store with its ttl, or
+// the error of creator if it is not cached.
+func (o *getOrCreateOptions) create(creator Creater, ttl time.Duration) (payload, time.Duration, error) {
+	value, err := creator()
+	if err != nil {
+		sentinel := o.sentinel(err)
+		if sentinel == nil || o.negativeTTL <= 0 {
+			return payload{}, 0, err
+		}
+		return payload{Error: err, sentinel: sentinel}, o.negativeTTLOf(ttl), nil
+	}
+
+	hard, refreshAfter := o.ttls(ttl)
+	p := payload{Content: value}
+	stamp(&p, refreshAfter)
+	return p, hard, nil
+}
+
+func (o *getOrCreateOptions) negativeTTLOf(ttl time.Duration) time.Duration {
+	if ttl > 0 && ttl < o.negativeTTL {
+		return ttl
+	}
+	return o.negativeTTL
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errForbidden = errors.New("forbidden")
+
+func testNegativeCaching(t *testing.T, cache CacheWithTTL) {
+	var calls atomic.Int32
+	failing := func(err error) Creater {
+		return func() (any, error) {
+			calls.Add(1)
+			return nil, err
+		}
+	}
+	getOrCreate := func(key string, creator Creater, opts ...GetOrCreateOption) error {
+		var out string
+		return cache.GetOrCreateWithTTL(key, time.Minute, creator, &out, opts...)
+	}
+	defer cache.Delete("negative_not_found")
+	defer cache.Delete("negative_cacheable")
+	defer cache.Delete("negative_other")
+
+	var out string
+	if err := cache.Get("negative_not_found", &out); !errors.Is(err, ErrNotFound) {
+		t.Errorf("miss error = %v, want ErrNotFound", err)
+	}
+
+	// ErrNotFound is cached for the negative ttl.
+	opt := WithNegativeTTL(100 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := getOrCreate("negative_not_found", failing(ErrNotFound), opt); !errors.Is(err, ErrNotFound) {
+			t.Errorf("not found error = %v", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("creator called %d times for a cached miss", calls.Load())
+	}
+	time.Sleep(150 * time.Millisecond)
+	getOrCreate("negative_not_found", failing(ErrNotFound), opt)
+	if calls.Load() != 2 {
+		t.Errorf("negative result kept after its ttl")
+	}
+
+	// The cacheable errors are matched with errors.Is, also when read back.
+	calls.Store(0)
+	wrapped := fmt.Errorf("user 42: %w", errForbidden)
+	for i := 0; i < 2; i++ {
+		err := getOrCreate("negative_cacheable", failing(wrapped), WithCacheableErrors(errForbidden))
+		if !errors.Is(err, errForbidden) || err.Error() != wrapped.Error() {
+			t.Errorf("cacheable error = %v", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("creator called %d times for a cacheable error", calls.Load())
+	}
+
+	// The other errors are not cached.
+	calls.Store(0)
+	for i := 0; i < 2; i++ {
+		if err := getOrCreate("negative_other", failing(errors.New("backend down"))); err == nil {
+			t.Errorf("error not returned")
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("non cacheable error cached")
+	}
+	var value string
+	err := cache.GetOrCreateWithTTL("negative_other", time.Minute, func() (any, error) { return "created", nil }, &value)
+	if err != nil || value != "created" {
+		t.Errorf("GetOrCreate after an error = %q, %v", value, err)
+	}
+}
+
+func TestMemoryCacheNegativeCaching(t *testing.T) {
+	mc := NewMemoryCache(time.Minute)
+	defer mc.Close()
+	testNegativeCaching(t, mc)
+}
+
+func TestRedisCacheNegativeCaching(t *testing.T) {
+	testNegativeCaching(t, redisCacheWithTTL{c})
+}
+
+func TestTieredCacheNegativeCaching(t *testing.T) {
+	testNegativeCaching(t, newTestTieredCache(t))
+}
@@ -1,24 +1,38 @@
 package cache
 
 import (
-	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/pkg/errors"
+	"github.com/superwhys/goutils/lg"
 	"github.com/superwhys/goutils/redisutils"
+	"golang.org/x/sync/singleflight"
 )
 
 var _ Cache = (*RedisCache)(nil)
 
 var (
-	defaultRedisTTL = time.Minute * 10
+	defaultRedisTTL  = time.Minute * 10
+	fillPollInterval = 50 * time.Millisecond
 )
 
 type RedisCache struct {
 	*redisutils.RedisClient
+	pool   *redis.Pool
 	prefix string
+	codec  Codec
+
+	compression     redisutils.Compression
+	minCompressSize int
+
+	fills     singleflight.Group
+	refreshes refresher
+	// refreshed is called with the keys refreshed in the background.
+	refreshed   func(key string)
+	fillLockTTL time.Duration
+	fillWait    time.Duration
 }
 
 type RedisCacheOption func(c *RedisCache)
@@ -29,9 +43,39 @@ func WithPrefix(prefix string) RedisCacheOption {
 	}
 }
 
+// WithCodec sets the codec of the values, JSONCodec by default. It must be
+// a codec of redisutils or registered with redisutils.RegisterCodec: its id
+// is stored with the values, so that the values of the previous codec are
+// still read after a change.
+func WithCodec(codec Codec) RedisCacheOption {
+	return func(c *RedisCache) {
+		c.codec = codec
+	}
+}
+
+// WithCompression compresses the values of at least minSize bytes.
+func WithCompression(compression redisutils.Compression, minSize int) RedisCacheOption {
+	return func(c *RedisCache) {
+		c.compression = compression
+		c.minCompressSize = minSize
+	}
+}
+
+// WithFillLock makes a single replica compute a missing value: GetOrCreate
+// takes a lock of lockTTL before calling the creator, and the other replicas
+// poll the key for at most wait before computing the value themselves.
+func WithFillLock(lockTTL, wait time.Duration) RedisCacheOption {
+	return func(c *RedisCache) {
+		c.fillLockTTL = lockTTL
+		c.fillWait = wait
+	}
+}
+
 func NewRedisCache(pool *redis.Pool, opts ...RedisCacheOption) *RedisCache {
 	rc := &RedisCache{
 		RedisClient: redisutils.NewRedisClient(pool),
+		pool:        pool,
+		codec:       JSONCodec{},
 	}
 
 	for _, opt := range opts {
@@ -42,35 +86,59 @@ func NewRedisCache(pool *redis.Pool, opts ...RedisCacheOption) *RedisCache {
 }
 
 func (c *RedisCache) Get(key string, out any) error {
-	var p payload
-
-	if err := c.RedisClient.Get(key, &p); err != nil {
+	data, err := c.getBytes(key)
+	if err != nil {
 		return err
 	}
+	if data == nil {
+		return ErrNotFound
+	}
+
+	p, err := c.decode(data)
+	if err != nil {
+		return errors.Wrap(err, "decode")
+	}
 
 	return p.Get(out)
 }
 
-func (c *RedisCache) Set(key string, value any) error {
-	return c.SetWithTTL(key, value, 0)
+// getPayload returns the payload of key and whether it was found.
+func (c *RedisCache) getPayload(key string) (payload, bool, error) {
+	data, err := c.getBytes(key)
+	if err != nil || data == nil {
+		return payload{}, false, err
+	}
+
+	p, err := c.decode(data)
+	if err != nil {
+		return payload{}, false, errors.Wrap(err, "decode")
+	}
+	return p, true, nil
+}
+
+func (c *RedisCache) Set(key string, value any, opts ...SetOption) error {
+	return setValues(c, map[string]any{key: value}, opts)
 }
 
-func (c *RedisCache) GetOrCreate(key string, creater Creater, out any) error {
-	return c.GetOrCreateWithTTL(key, 0, creater, out)
+func (c *RedisCache) GetOrCreate(key string, creater Creater, out any, opts ...GetOrCreateOption) error {
+	return c.GetOrCreateWithTTL(key, 0, creater, out, opts...)
 }
 
 func (c *RedisCache) Delete(key string) error {
-	return c.RedisClient.Delete(key)
+	return c.DeleteMany([]string{key})
 }
 
-func (c *RedisCache) setWithTTL(conn redis.Conn, key string, value any, ttl time.Duration) (err error) {
+// sendSet sends the SET of key, and the DEL of its tags, which the keys set
+// without tags lose like in MemoryCache. Both are replied to.
+func (c *RedisCache) sendSet(conn redis.Conn, key string, data []byte, ttl time.Duration) error {
+	args := []any{c.packKey(key), data}
 	if ttl > 0 {
-		_, err = conn.Do("SET", key, value, "EX", int(ttl.Seconds()))
-	} else {
-		_, err = conn.Do("SET", key, value)
+		args = append(args, "PX", ttl.Milliseconds())
 	}
-
-	return
+	if err := conn.Send("SET", args...); err != nil {
+		return err
+	}
+	return conn.Send("DEL", c.keyTagsKey(key))
 }
 
 func (c *RedisCache) packKey(key string) string {
@@ -81,57 +149,352 @@ func (c *RedisCache) packKey(key string) string {
 	return key
 }
 
-func (c *RedisCache) GetOrCreateWithTTL(key string, ttl time.Duration, creator Creater, out any) error {
-	conn := c.GetConn()
-	defer conn.Close()
+func (c *RedisCache) GetOrCreateWithTTL(key string, ttl time.Duration, creator Creater, out any, opts ...GetOrCreateOption) error {
+	p, err := c.getOrCreate(key, ttl, creator, newGetOrCreateOptions(opts))
+	if err != nil {
+		return err
+	}
 
-	key = c.packKey(key)
+	return p.Get(out)
+}
 
-	var p payload
-	data, err := redis.Bytes(conn.Do("GET", key))
-	// no data in redis
-	if err != nil && errors.Is(err, redis.ErrNil) {
-		p = newPayload(creator())
-		data, err = json.Marshal(p)
+func (c *RedisCache) getOrCreate(key string, ttl time.Duration, creator Creater, o *getOrCreateOptions) (payload, error) {
+	data, err := c.getBytes(key)
+	if err != nil {
+		return payload{}, err
+	}
+
+	if data == nil {
+		data, err = c.fillBytes(key, func() ([]byte, time.Duration, error) {
+			p, pttl, err := o.create(creator, ttl)
+			if err != nil {
+				return nil, 0, err
+			}
+			data, err := c.encode(p, pttl)
+			return data, pttl, errors.Wrap(err, "encode.redisData")
+		})
 		if err != nil {
-			return errors.Wrap(err, "json.Marshal.redisData")
+			return payload{}, err
 		}
+	}
 
-		if err = c.setWithTTL(conn, key, data, ttl); err != nil {
-			return errors.Wrap(err, "redis.setTTL")
+	p, err := c.decode(data)
+	if err != nil {
+		return payload{}, errors.Wrap(err, "decode.redisData")
+	}
+
+	if p.needsRefresh() {
+		c.refreshes.run(key, func() {
+			c.refresh(key, ttl, creator, o)
+		})
+	}
+	return p, nil
+}
+
+// refresh replaces the value of key, which is kept if the creator fails.
+// With the fill lock, a single replica refreshes it.
+func (c *RedisCache) refresh(key string, ttl time.Duration, creator Creater, o *getOrCreateOptions) {
+	if c.fillLockTTL > 0 {
+		lockKey := c.packKey(key) + "::fill"
+		token, err := c.LockWithToken(lockKey, c.fillLockTTL)
+		if err != nil {
+			return
 		}
-		return nil
+		defer c.UnLockWithToken(lockKey, token)
 	}
 
-	// other error
+	value, err := creator()
 	if err != nil {
-		return errors.Wrap(err, "do.redis.get")
+		lg.Warnf("cache: refresh %s: %v", key, err)
+		return
+	}
+	hard, refreshAfter := o.ttls(ttl)
+	p := payload{Content: value}
+	stamp(&p, refreshAfter)
+	data, err := c.encode(p, hard)
+	if err == nil {
+		err = c.setBytes(key, data, hard)
 	}
+	if err != nil {
+		lg.Errorf("cache: refresh %s: %v", key, err)
+		return
+	}
+	if c.refreshed != nil {
+		c.refreshed(key)
+	}
+}
+
+// fillBytes sets key to the data returned by load, for the ttl returned by
+// load, and returns it. The
+// concurrent fills of key are done once in the process, and once among the
+// replicas with the fill lock, the other ones waiting for the value.
+func (c *RedisCache) fillBytes(key string, load func() ([]byte, time.Duration, error)) ([]byte, error) {
+	v, err, _ := c.fills.Do(key, func() (any, error) {
+		// The key may have been set while waiting for the previous fill.
+		if data, err := c.getBytes(key); err != nil || data != nil {
+			return data, err
+		}
+
+		if c.fillLockTTL > 0 {
+			lockKey := c.packKey(key) + "::fill"
+			token, err := c.LockWithToken(lockKey, c.fillLockTTL)
+			switch {
+			case err == nil:
+				defer c.UnLockWithToken(lockKey, token)
+				if data, err := c.getBytes(key); err != nil || data != nil {
+					return data, err
+				}
+			case errors.Is(err, redisutils.ErrLockFailed):
+				if data, err := c.waitFill(key); err != nil || data != nil {
+					return data, err
+				}
+				lg.Warnf("cache: fill of %s took longer than %v, computing it", key, c.fillWait)
+			default:
+				lg.Errorf("cache: lock the fill of %s: %v", key, err)
+			}
+		}
 
-	// get data from redis
-	if err := json.Unmarshal(data, &p); err != nil {
-		return errors.Wrap(err, "json.Unmarshal.redisData")
+		data, ttl, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.setBytes(key, data, ttl); err != nil {
+			return nil, err
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return v.([]byte), nil
+}
 
-	return p.Get(out)
+// waitFill polls key while another replica holds the fill lock, nil if it
+// is not set after the fill wait.
+func (c *RedisCache) waitFill(key string) ([]byte, error) {
+	deadline := time.Now().Add(c.fillWait)
+	for time.Now().Before(deadline) {
+		time.Sleep(fillPollInterval)
+		if data, err := c.getBytes(key); err != nil || data != nil {
+			return data, err
+		}
+	}
+	return nil, nil
 }
 
 func (c *RedisCache) SetWithTTL(key string, value any, ttl time.Duration) error {
-	p := payload{Content: value}
-	data, err := json.Marshal(p)
+	data, err := c.encode(payload{Content: value}, ttl)
 	if err != nil {
-		return errors.Wrap(err, "json.Marshal.redisData")
+		return errors.Wrap(err, "encode.redisData")
 	}
 
-	key = c.packKey(key)
+	return c.setBytes(key, data, ttl)
+}
 
-	if ttl > 0 {
-		_, err = c.RedisClient.Do("SET", key, data, "EX", int(ttl.Seconds()))
-	} else {
-		_, err = c.RedisClient.Do("SET", key, data)
+// getBytes returns the encoded payload of key, nil if there is none.
+func (c *RedisCache) getBytes(key string) ([]byte, error) {
+	data, err := redis.Bytes(c.RedisClient.Do("GET", c.packKey(key)))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "do.redis.get")
+	}
+	return data, nil
+}
+
+func (c *RedisCache) setBytes(key string, data []byte, ttl time.Duration) error {
+	conn := c.GetConn()
+	defer conn.Close()
+
+	if err := c.sendSet(conn, key, data, ttl); err != nil {
+		return errors.Wrap(err, "send")
+	}
+	return errors.Wrap(receiveAll(conn, 2), "do.redis.set")
+}
+
+// receiveAll flushes conn and receives n replies, returning the first error.
+func receiveAll(conn redis.Conn, n int) error {
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	var first error
+	for i := 0; i < n; i++ {
+		if _, err := conn.Receive(); err != nil && first == nil {
+			first = err
+		}
 	}
+	return first
+}
+
+func (c *RedisCache) GetMany(keys []string, out any) error {
+	return getMany(c, keys, out)
+}
+
+func (c *RedisCache) SetMany(values map[string]any, opts ...SetOption) error {
+	return setValues(c, values, opts)
+}
+
+func (c *RedisCache) DeleteMany(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := make([]any, 0, 2*len(keys))
+	for _, key := range keys {
+		args = append(args, c.packKey(key), c.keyTagsKey(key))
+	}
+	_, err := c.RedisClient.Do("DEL", args...)
+	return errors.Wrap(err, "do.redis.del")
+}
+
+func (c *RedisCache) GetOrCreateMany(keys []string, creator BatchCreater, out any, opts ...GetOrCreateOption) error {
+	return c.GetOrCreateManyWithTTL(keys, 0, creator, out, opts...)
+}
+
+func (c *RedisCache) GetOrCreateManyWithTTL(keys []string, ttl time.Duration, creator BatchCreater, out any, opts ...GetOrCreateOption) error {
+	return getOrCreateMany(c, keys, ttl, creator, out, newGetOrCreateOptions(opts))
+}
+
+// setTaggedScript sets KEYS[1] to ARGV[1] for ARGV[2] milliseconds, replaces
+// the tags of KEYS[1] in the set KEYS[2] by the tags ARGV[4:], and adds its
+// key ARGV[3] to their tag sets in KEYS[3:], which live as long as their
+// longest key.
+var setTaggedScript = redis.NewScript(-1, `
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+redis.call("DEL", KEYS[2])
+redis.call("SADD", KEYS[2], unpack(ARGV, 4))
+if ttl > 0 then
+	redis.call("PEXPIRE", KEYS[2], ttl)
+end
+for i = 3, #KEYS do
+	local fresh = redis.call("EXISTS", KEYS[i]) == 0
+	redis.call("SADD", KEYS[i], ARGV[3])
+	local pttl = redis.call("PTTL", KEYS[i])
+	if ttl == 0 then
+		if pttl >= 0 then
+			redis.call("PERSIST", KEYS[i])
+		end
+	elseif fresh or (pttl >= 0 and pttl < ttl) then
+		redis.call("PEXPIRE", KEYS[i], ttl)
+	end
+end
+return 1
+`)
+
+// invalidateTagScript deletes the keys of the tag set KEYS[1], prefixed by
+// ARGV[1], which still have the tag ARGV[3] in their set of tags prefixed by
+// ARGV[2], and the tag set, returning the deleted keys.
+var invalidateTagScript = redis.NewScript(1, `
+local deleted = {}
+for _, key in ipairs(redis.call("SMEMBERS", KEYS[1])) do
+	local tags = ARGV[2] .. key
+	if redis.call("SISMEMBER", tags, ARGV[3]) == 1 then
+		redis.call("DEL", ARGV[1] .. key, tags)
+		table.insert(deleted, key)
+	end
+end
+redis.call("DEL", KEYS[1])
+return deleted
+`)
+
+// tagKey is the key of the set of the keys of tag. The tag sets and the key
+// tags are out of the keys of the cache, under their own prefixes.
+func (c *RedisCache) tagKey(tag string) string {
+	return "cache-tag::" + c.packKey(tag)
+}
 
-	return errors.Wrap(err, "do.redis.set")
+// keyTagsKey is the key of the set of the tags of key.
+func (c *RedisCache) keyTagsKey(key string) string {
+	return "cache-tags::" + c.packKey(key)
+}
+
+// InvalidateTag deletes the keys set with tag. Like in MemoryCache, a key set
+// again without the tag is kept.
+func (c *RedisCache) InvalidateTag(tag string) error {
+	_, err := c.invalidateTag(tag)
+	return err
+}
+
+// invalidateTag deletes the keys of tag and returns them.
+func (c *RedisCache) invalidateTag(tag string) ([]string, error) {
+	conn := c.GetConn()
+	defer conn.Close()
+
+	keys, err := redis.Strings(invalidateTagScript.Do(conn, c.tagKey(tag), c.packKey(""), c.keyTagsKey(""), tag))
+	return keys, errors.Wrap(err, "invalidateTag")
+}
+
+func (c *RedisCache) getPayloads(keys []string) (map[string]payload, error) {
+	payloads := make(map[string]payload, len(keys))
+	if len(keys) == 0 {
+		return payloads, nil
+	}
+
+	args := make([]any, len(keys))
+	for i, key := range keys {
+		args[i] = c.packKey(key)
+	}
+	datas, err := redis.ByteSlices(c.RedisClient.Do("MGET", args...))
+	if err != nil {
+		return nil, errors.Wrap(err, "do.redis.mget")
+	}
+	for i, data := range datas {
+		if data == nil {
+			continue
+		}
+		p, err := c.decode(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode %s", keys[i])
+		}
+		payloads[keys[i]] = p
+	}
+	return payloads, nil
+}
+
+// setPayloads sets the payloads in a pipeline.
+func (c *RedisCache) setPayloads(payloads map[string]payload, ttl time.Duration, tags []string) error {
+	datas := make(map[string][]byte, len(payloads))
+	for key, p := range payloads {
+		data, err := c.encode(p, ttl)
+		if err != nil {
+			return errors.Wrapf(err, "encode %s", key)
+		}
+		datas[key] = data
+	}
+	if len(datas) == 0 {
+		return nil
+	}
+
+	conn := c.GetConn()
+	defer conn.Close()
+	replies := 0
+	for key, data := range datas {
+		var err error
+		if len(tags) == 0 {
+			err = c.sendSet(conn, key, data, ttl)
+			replies += 2
+		} else {
+			args := []any{2 + len(tags), c.packKey(key), c.keyTagsKey(key)}
+			for _, tag := range tags {
+				args = append(args, c.tagKey(tag))
+			}
+			args = append(args, data, ttl.Milliseconds(), key)
+			for _, tag := range tags {
+				args = append(args, tag)
+			}
+			err = setTaggedScript.Send(conn, args...)
+			replies++
+		}
+		if err != nil {
+			return errors.Wrap(err, "send")
+		}
+	}
+	return errors.Wrap(receiveAll(conn, replies), "do.redis.set")
 }
 
 func (c *RedisCache) Close() error {
@@ -6,7 +6,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/gomodule/redigo/redis"
 	"github.com/superwhys/goutils/dialer"
 )
 
@@ -133,7 +132,7 @@ func TestRedisCache_Delete(t *testing.T) {
 			var out string
 			c.Delete(tt.args.key)
 			err := c.Get(tt.args.key, &out)
-			if err != nil && !errors.Is(err, redis.ErrNil) {
+			if err != nil && !errors.Is(err, ErrNotFound) {
 				t.Errorf("redisCache get key error = %v", err)
 			}
 
@@ -160,12 +159,12 @@ func TestRedisCache_GetOrCreateWithTTL(t *testing.T) {
 		{
 			"test_not_exists_key", args{"test_create_key_ttl", time.Second * 5, func() (any, error) {
 				return "create_value", nil
-			}, "", ""}, false,
+			}, "", "create_value"}, false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := c.GetOrCreateWithTTL(tt.args.key, tt.args.ttl, tt.args.creator, tt.args.out)
+			err := c.GetOrCreateWithTTL(tt.args.key, tt.args.ttl, tt.args.creator, &tt.args.out)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("RedisCache.GetOrCreateWithTTL() error = %v, wantErr %v", err, tt.wantErr)
 			}
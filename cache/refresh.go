@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type GetOrCreateOption func(*getOrCreateOptions)
+
+type getOrCreateOptions struct {
+	softTTL      time.Duration
+	refreshAhead float64
+	jitter       float64
+	negativeTTL  time.Duration
+	cacheable    []error
+}
+
+// WithSoftTTL makes a value stale after ttl, before its hard ttl: a stale
+// value is returned at once, and refreshed in the background.
+func WithSoftTTL(ttl time.Duration) GetOrCreateOption {
+	return func(o *getOrCreateOptions) {
+		o.softTTL = ttl
+	}
+}
+
+// WithRefreshAhead refreshes a value in the background when the part of its
+// soft ttl, or else of its ttl, left is below ratio, e.g. 0.2 for the last
+// fifth, so that it is rarely seen stale or expired.
+func WithRefreshAhead(ratio float64) GetOrCreateOption {
+	return func(o *getOrCreateOptions) {
+		o.refreshAhead = ratio
+	}
+}
+
+// WithTTLJitter spreads the ttls of the values by a random part of them, at
+// most fraction, e.g. 0.1 for ±10%, so that the values created together do
+// not expire together.
+func WithTTLJitter(fraction float64) GetOrCreateOption {
+	return func(o *getOrCreateOptions) {
+		o.jitter = fraction
+	}
+}
+
+func newGetOrCreateOptions(opts []GetOrCreateOption) *getOrCreateOptions {
+	o := &getOrCreateOptions{negativeTTL: defaultNegativeTTL}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *getOrCreateOptions) jittered(ttl time.Duration) time.Duration {
+	if ttl <= 0 || o.jitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration((rand.Float64()*2-1)*o.jitter*float64(ttl))
+}
+
+// ttls returns the hard ttl of a value and the duration after which it is
+// refreshed in the background, 0 for never.
+func (o *getOrCreateOptions) ttls(ttl time.Duration) (hard, refreshAfter time.Duration) {
+	hard = o.jittered(ttl)
+	soft := o.jittered(o.softTTL)
+	if soft <= 0 || (hard > 0 && soft > hard) {
+		soft = 0
+		if o.refreshAhead > 0 {
+			soft = hard
+		}
+	}
+	if soft <= 0 {
+		return hard, 0
+	}
+	if o.refreshAhead > 0 && o.refreshAhead < 1 {
+		return hard, soft - time.Duration(o.refreshAhead*float64(soft))
+	}
+	return hard, soft
+}
+
+// stamp sets the time at which p is refreshed.
+func stamp(p *payload, refreshAfter time.Duration) {
+	if refreshAfter > 0 {
+		p.RefreshAt = time.Now().Add(refreshAfter).UnixMilli()
+	}
+}
+
+func (p payload) needsRefresh() bool {
+	return p.RefreshAt > 0 && time.Now().UnixMilli() >= p.RefreshAt
+}
+
+// refresher runs at most one background refresh by key.
+type refresher struct {
+	running sync.Map
+}
+
+func (r *refresher) run(key string, refresh func()) {
+	if _, loaded := r.running.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer r.running.Delete(key)
+		refresh()
+	}()
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrCreateTTLs(t *testing.T) {
+	tests := []struct {
+		name             string
+		ttl              time.Duration
+		opts             []GetOrCreateOption
+		hard, refreshAft time.Duration
+	}{
+		{"none", time.Minute, nil, time.Minute, 0},
+		{"soft", time.Minute, []GetOrCreateOption{WithSoftTTL(10 * time.Second)}, time.Minute, 10 * time.Second},
+		{"soft over hard", time.Minute, []GetOrCreateOption{WithSoftTTL(time.Hour)}, time.Minute, 0},
+		{"refresh ahead", time.Minute, []GetOrCreateOption{WithRefreshAhead(0.25)}, time.Minute, 45 * time.Second},
+		{"soft and ahead", 0, []GetOrCreateOption{WithSoftTTL(40 * time.Second), WithRefreshAhead(0.5)}, 0, 20 * time.Second},
+	}
+	for _, tt := range tests {
+		hard, refreshAfter := newGetOrCreateOptions(tt.opts).ttls(tt.ttl)
+		if hard != tt.hard || refreshAfter != tt.refreshAft {
+			t.Errorf("%s: ttls = %v, %v, want %v, %v", tt.name, hard, refreshAfter, tt.hard, tt.refreshAft)
+		}
+	}
+
+	o := newGetOrCreateOptions([]GetOrCreateOption{WithTTLJitter(0.1)})
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 20; i++ {
+		hard, _ := o.ttls(time.Minute)
+		if hard < 54*time.Second || hard > 66*time.Second {
+			t.Fatalf("jittered ttl %v out of range", hard)
+		}
+		seen[hard] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("ttls are not jittered")
+	}
+}
+
+// countingCreator returns the count of its calls, failing when fail is set.
+func countingCreator(calls *atomic.Int32, fail *atomic.Bool) Creater {
+	return func() (any, error) {
+		n := calls.Add(1)
+		if fail.Load() {
+			return nil, errors.New("creator failed")
+		}
+		return int(n), nil
+	}
+}
+
+func testStaleWhileRevalidate(t *testing.T, cache CacheWithTTL, key string) {
+	var (
+		calls atomic.Int32
+		fail  atomic.Bool
+	)
+	creator := countingCreator(&calls, &fail)
+	get := func() int {
+		var out int
+		if err := cache.GetOrCreateWithTTL(key, time.Minute, creator, &out, WithSoftTTL(50*time.Millisecond)); err != nil {
+			t.Fatalf("GetOrCreate: %v", err)
+		}
+		return out
+	}
+	waitCalls := func(n int32) {
+		for i := 0; i < 100 && calls.Load() < n; i++ {
+			time.Sleep(5 * time.Millisecond)
+		}
+		// Let the refresh store its value.
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if v := get(); v != 1 {
+		t.Fatalf("first value = %d", v)
+	}
+	if v := get(); v != 1 || calls.Load() != 1 {
+		t.Fatalf("fresh value = %d after %d calls", v, calls.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if v := get(); v != 1 {
+		t.Errorf("stale value = %d, want 1", v)
+	}
+	waitCalls(2)
+	if v := get(); v != 2 {
+		t.Errorf("refreshed value = %d, want 2", v)
+	}
+
+	fail.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	get()
+	waitCalls(3)
+	if v := get(); v != 2 {
+		t.Errorf("a failed refresh should keep the stale value, got %d", v)
+	}
+}
+
+func TestMemoryCacheStaleWhileRevalidate(t *testing.T) {
+	mc := NewMemoryCache(time.Minute)
+	defer mc.Close()
+	testStaleWhileRevalidate(t, mc, "swr_key")
+}
+
+type redisCacheWithTTL struct {
+	*RedisCache
+}
+
+func (c redisCacheWithTTL) SetWithTTL(key string, ttl time.Duration, value any) error {
+	return c.RedisCache.SetWithTTL(key, value, ttl)
+}
+
+func TestRedisCacheStaleWhileRevalidate(t *testing.T) {
+	defer c.Delete("swr_key")
+	testStaleWhileRevalidate(t, redisCacheWithTTL{c}, "swr_key")
+}
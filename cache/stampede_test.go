@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/superwhys/goutils/dialer"
+)
+
+func TestMemoryCacheSingleflight(t *testing.T) {
+	mc := NewMemoryCache(time.Minute)
+	defer mc.Close()
+
+	var calls atomic.Int32
+	creator := func() (any, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out string
+			if err := mc.GetOrCreate("stampede_key", creator, &out); err != nil || out != "value" {
+				t.Errorf("GetOrCreate = %q, %v", out, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("creator called %d times, want 1", n)
+	}
+}
+
+func TestRedisCacheFillLock(t *testing.T) {
+	pool := dialer.DialRedisPool("localhost:6379", 2, 100)
+	// Each cache stands for a replica, with its own singleflight.
+	replicas := []*RedisCache{
+		NewRedisCache(pool, WithPrefix("stampede"), WithFillLock(time.Second, time.Second)),
+		NewRedisCache(pool, WithPrefix("stampede"), WithFillLock(time.Second, time.Second)),
+		NewRedisCache(pool, WithPrefix("stampede"), WithFillLock(time.Second, time.Second)),
+	}
+	defer replicas[0].Delete("fill_key")
+
+	var calls atomic.Int32
+	creator := func() (any, error) {
+		calls.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go func(rc *RedisCache) {
+			defer wg.Done()
+			var out string
+			if err := rc.GetOrCreateWithTTL("fill_key", time.Minute, creator, &out); err != nil || out != "value" {
+				t.Errorf("GetOrCreate = %q, %v", out, err)
+			}
+		}(replicas[i%len(replicas)])
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("creator called %d times, want 1", n)
+	}
+	if _, err := replicas[0].LockWithToken("stampede::fill_key::fill", time.Second); err != nil {
+		t.Errorf("fill lock not released: %v", err)
+	}
+}
@@ -0,0 +1,370 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/superwhys/goutils/lg"
+	"golang.org/x/sync/singleflight"
+)
+
+var _ CacheWithTTL = (*TieredCache)(nil)
+
+const (
+	defaultL1TTL         = time.Minute
+	pubsubHealthInterval = 30 * time.Second
+	resubscribeBackoff   = time.Second
+)
+
+// TieredCache reads through a MemoryCache (L1) in front of a RedisCache (L2)
+// shared by the replicas. The writes and deletes are published on a redis
+// channel, and every replica evicts the key from its L1 on receipt. The L1
+// ttl bounds the staleness of a replica missing messages, e.g. while it
+// resubscribes, and the whole L1 is purged after a resubscription.
+type TieredCache struct {
+	l1      *MemoryCache
+	l2      *RedisCache
+	l1TTL   time.Duration
+	channel string
+	id      string
+
+	fills  singleflight.Group
+	cancel func()
+	done   chan struct{}
+}
+
+// dialSubscription dials the connection of the subscription, out of the
+// pool which would unsubscribe it when closed while it is being read.
+var dialSubscription = func(pool *redis.Pool) (redis.Conn, error) {
+	return pool.Dial()
+}
+
+type TieredCacheOption func(*TieredCache)
+
+// WithL1TTL sets the longest time a value is kept in L1, 1 minute by default.
+func WithL1TTL(ttl time.Duration) TieredCacheOption {
+	return func(tc *TieredCache) {
+		tc.l1TTL = ttl
+	}
+}
+
+// WithInvalidationChannel sets the channel of the invalidation messages, which
+// is cache-invalidate followed by the prefix of L2 by default.
+func WithInvalidationChannel(channel string) TieredCacheOption {
+	return func(tc *TieredCache) {
+		tc.channel = channel
+	}
+}
+
+// NewTieredCache returns a cache owning l1 and l2, which are closed by Close.
+func NewTieredCache(l1 *MemoryCache, l2 *RedisCache, opts ...TieredCacheOption) *TieredCache {
+	tc := &TieredCache{
+		l1:      l1,
+		l2:      l2,
+		l1TTL:   defaultL1TTL,
+		channel: "cache-invalidate",
+		id:      uuid.NewString(),
+		done:    make(chan struct{}),
+	}
+	if l2.prefix != "" {
+		tc.channel += "::" + l2.prefix
+	}
+	for _, opt := range opts {
+		opt(tc)
+	}
+	l2.refreshed = func(key string) { tc.invalidate(key) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tc.cancel = cancel
+	go tc.subscribe(ctx)
+	return tc
+}
+
+func (tc *TieredCache) l1TTLOf(ttl time.Duration) time.Duration {
+	if ttl > 0 && ttl < tc.l1TTL {
+		return ttl
+	}
+	return tc.l1TTL
+}
+
+func (tc *TieredCache) Get(key string, out any) error {
+	if p, ok := tc.l1.lookup(key); ok {
+		return p.Get(out)
+	}
+
+	p, ok, err := tc.l2.getPayload(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	tc.storeL1(key, tc.l1TTL, p)
+	return p.Get(out)
+}
+
+func (tc *TieredCache) Set(key string, value any, opts ...SetOption) error {
+	return setValues(tc, map[string]any{key: value}, opts)
+}
+
+func (tc *TieredCache) SetWithTTL(key string, ttl time.Duration, value any) error {
+	if err := tc.l2.SetWithTTL(key, value, ttl); err != nil {
+		return err
+	}
+	tc.invalidate(key)
+	tc.l1.store(key, tc.l1TTLOf(ttl), payload{Content: value})
+	return nil
+}
+
+func (tc *TieredCache) GetOrCreate(key string, creater Creater, out any, opts ...GetOrCreateOption) error {
+	return tc.GetOrCreateWithTTL(key, 0, creater, out, opts...)
+}
+
+// GetOrCreateWithTTL creates the value in L2 as RedisCache does, with the
+// options applying to L2. A value to be refreshed is refreshed in L2 in the
+// background, and evicted from the L1 of every replica once refreshed.
+func (tc *TieredCache) GetOrCreateWithTTL(key string, ttl time.Duration, creator Creater, out any, opts ...GetOrCreateOption) error {
+	o := newGetOrCreateOptions(opts)
+	if p, ok := tc.l1.lookup(key); ok {
+		if p.needsRefresh() {
+			tc.l2.refreshes.run(key, func() {
+				tc.l2.refresh(key, ttl, creator, o)
+			})
+		}
+		return p.Get(out)
+	}
+
+	v, err, _ := tc.fills.Do(key, func() (any, error) {
+		p, err := tc.l2.getOrCreate(key, ttl, creator, o)
+		if err != nil {
+			return nil, err
+		}
+		tc.storeL1(key, tc.l1TTLOf(ttl), p)
+		return p, nil
+	})
+	if err != nil {
+		return err
+	}
+	return v.(payload).Get(out)
+}
+
+func (tc *TieredCache) Delete(key string) error {
+	if err := tc.l2.Delete(key); err != nil {
+		return err
+	}
+	tc.invalidate(key)
+	return nil
+}
+
+func (tc *TieredCache) GetMany(keys []string, out any) error {
+	return getMany(tc, keys, out)
+}
+
+func (tc *TieredCache) SetMany(values map[string]any, opts ...SetOption) error {
+	return setValues(tc, values, opts)
+}
+
+func (tc *TieredCache) DeleteMany(keys []string) error {
+	if err := tc.l2.DeleteMany(keys); err != nil {
+		return err
+	}
+	tc.invalidate(keys...)
+	return nil
+}
+
+func (tc *TieredCache) GetOrCreateMany(keys []string, creator BatchCreater, out any, opts ...GetOrCreateOption) error {
+	return tc.GetOrCreateManyWithTTL(keys, 0, creator, out, opts...)
+}
+
+func (tc *TieredCache) GetOrCreateManyWithTTL(keys []string, ttl time.Duration, creator BatchCreater, out any, opts ...GetOrCreateOption) error {
+	return getOrCreateMany(tc, keys, ttl, creator, out, newGetOrCreateOptions(opts))
+}
+
+// InvalidateTag deletes the keys of tag from L2, and from the L1 of every
+// replica.
+func (tc *TieredCache) InvalidateTag(tag string) error {
+	keys, err := tc.l2.invalidateTag(tag)
+	if err != nil {
+		return err
+	}
+	tc.invalidate(keys...)
+	return nil
+}
+
+func (tc *TieredCache) getPayloads(keys []string) (map[string]payload, error) {
+	payloads := make(map[string]payload, len(keys))
+	var misses []string
+	for _, key := range keys {
+		if p, ok := tc.l1.lookup(key); ok {
+			payloads[key] = p
+		} else {
+			misses = append(misses, key)
+		}
+	}
+	if len(misses) == 0 {
+		return payloads, nil
+	}
+
+	found, err := tc.l2.getPayloads(misses)
+	if err != nil {
+		return nil, err
+	}
+	for key, p := range found {
+		tc.storeL1(key, tc.l1TTL, p)
+		payloads[key] = p
+	}
+	return payloads, nil
+}
+
+func (tc *TieredCache) setPayloads(payloads map[string]payload, ttl time.Duration, tags []string) error {
+	if err := tc.l2.setPayloads(payloads, ttl, tags); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(payloads))
+	for key := range payloads {
+		keys = append(keys, key)
+	}
+	tc.invalidate(keys...)
+	for key, p := range payloads {
+		tc.l1.store(key, tc.l1TTLOf(ttl), p)
+	}
+	return nil
+}
+
+// storeL1 keeps p, read from L2, in L1 for ttl bounded by the time p has
+// left in L2, e.g. the negative ttl of a negative payload.
+func (tc *TieredCache) storeL1(key string, ttl time.Duration, p payload) {
+	if !p.expireAt.IsZero() {
+		left := time.Until(p.expireAt)
+		if left <= 0 {
+			return
+		}
+		if ttl <= 0 || left < ttl {
+			ttl = left
+		}
+	}
+	tc.l1.store(key, ttl, p)
+}
+
+// invalidate evicts the keys from L1 and publishes them to the other
+// replicas, in a pipeline.
+func (tc *TieredCache) invalidate(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	for _, key := range keys {
+		tc.l1.Delete(key)
+	}
+
+	conn := tc.l2.GetConn()
+	defer conn.Close()
+	for _, key := range keys {
+		if err := conn.Send("PUBLISH", tc.channel, tc.id+" "+key); err != nil {
+			lg.Errorf("cache: publish the invalidation of %s: %v", key, err)
+			return
+		}
+	}
+	if err := receiveAll(conn, len(keys)); err != nil {
+		lg.Errorf("cache: publish the invalidation of %d keys: %v", len(keys), err)
+	}
+}
+
+func (tc *TieredCache) handleMessage(data []byte) {
+	id, key, ok := strings.Cut(string(data), " ")
+	if !ok || id == tc.id {
+		return
+	}
+	tc.l1.Delete(key)
+}
+
+// subscribe receives the invalidations until ctx is done, subscribing again
+// when the connection is lost.
+func (tc *TieredCache) subscribe(ctx context.Context) {
+	defer close(tc.done)
+
+	for first := true; ctx.Err() == nil; first = false {
+		if !first {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resubscribeBackoff):
+			}
+		}
+
+		resubscribed := !first
+		err := tc.receive(ctx, func() {
+			if resubscribed {
+				// The messages sent while resubscribing are lost.
+				tc.l1.purge()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			lg.Errorf("cache: subscription to %s lost: %v", tc.channel, err)
+		}
+	}
+}
+
+func (tc *TieredCache) receive(ctx context.Context, subscribed func()) error {
+	conn, err := dialSubscription(tc.l2.pool)
+	if err != nil {
+		return err
+	}
+
+	psc := redis.PubSubConn{Conn: conn}
+	defer psc.Close()
+	if err := psc.Subscribe(tc.channel); err != nil {
+		return err
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			switch v := psc.Receive().(type) {
+			case redis.Message:
+				tc.handleMessage(v.Data)
+			case redis.Subscription:
+				if v.Kind == "subscribe" {
+					subscribed()
+				}
+				if v.Count == 0 {
+					errc <- nil
+					return
+				}
+			case error:
+				errc <- v
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pubsubHealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			psc.Unsubscribe()
+			select {
+			case <-errc:
+			case <-time.After(time.Second):
+			}
+			return nil
+		case err := <-errc:
+			return err
+		case <-ticker.C:
+			if err := psc.Ping(""); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close stops the subscription and closes L1 and L2.
+func (tc *TieredCache) Close() error {
+	tc.cancel()
+	<-tc.done
+	tc.l1.Close()
+	return tc.l2.Close()
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/superwhys/goutils/dialer"
+)
+
+func newTestTieredCache(t *testing.T) *TieredCache {
+	pool := dialer.DialRedisPool("localhost:6379", 2, 100)
+	tc := NewTieredCache(NewMemoryCache(time.Minute), NewRedisCache(pool, WithPrefix("tiered")))
+	t.Cleanup(func() { tc.Close() })
+	return tc
+}
+
+// waitValue polls key on tc until it is want.
+func waitValue(t *testing.T, tc *TieredCache, key, want string) {
+	t.Helper()
+	var out string
+	for i := 0; i < 100; i++ {
+		if err := tc.Get(key, &out); err == nil && out == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("value of %s = %q, want %q", key, out, want)
+}
+
+func TestTieredCacheInvalidation(t *testing.T) {
+	a, b := newTestTieredCache(t), newTestTieredCache(t)
+	defer a.Delete("tiered_key")
+	// Let the subscriptions start.
+	time.Sleep(50 * time.Millisecond)
+
+	if err := a.Set("tiered_key", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	waitValue(t, b, "tiered_key", "v1")
+	if _, ok := b.l1.lookup("tiered_key"); !ok {
+		t.Errorf("value read from L2 is not kept in L1")
+	}
+
+	a.Set("tiered_key", "v2")
+	waitValue(t, b, "tiered_key", "v2")
+
+	a.Delete("tiered_key")
+	for i := 0; i < 100; i++ {
+		if _, ok := b.l1.lookup("tiered_key"); !ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var out string
+	if err := b.Get("tiered_key", &out); err == nil {
+		t.Errorf("deleted key found: %q", out)
+	}
+}
+
+func TestTieredCacheGetOrCreate(t *testing.T) {
+	tc := newTestTieredCache(t)
+	defer tc.Delete("tiered_create_key")
+
+	calls := 0
+	creator := func() (any, error) {
+		calls++
+		return "created", nil
+	}
+	for i := 0; i < 2; i++ {
+		var out string
+		if err := tc.GetOrCreateWithTTL("tiered_create_key", time.Minute, creator, &out); err != nil || out != "created" {
+			t.Fatalf("GetOrCreate = %q, %v", out, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("creator called %d times", calls)
+	}
+	if _, ok, _ := tc.l2.getPayload("tiered_create_key"); !ok {
+		t.Errorf("created value not stored in L2")
+	}
+}
+
+func TestTieredCacheResubscribe(t *testing.T) {
+	var mu sync.Mutex
+	var conns []redis.Conn
+	dial := dialSubscription
+	dialSubscription = func(pool *redis.Pool) (redis.Conn, error) {
+		conn, err := dial(pool)
+		if err == nil {
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+		return conn, err
+	}
+	t.Cleanup(func() { dialSubscription = dial })
+
+	a, b := newTestTieredCache(t), newTestTieredCache(t)
+	defer a.Delete("tiered_resub_key")
+	time.Sleep(50 * time.Millisecond)
+
+	if err := a.Set("tiered_resub_key", "v1"); err != nil {
+		t.Skipf("redis unreachable: %v", err)
+	}
+	waitValue(t, b, "tiered_resub_key", "v1")
+
+	// Drop the subscriptions, which subscribe again after a backoff.
+	mu.Lock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	mu.Unlock()
+	time.Sleep(resubscribeBackoff + 200*time.Millisecond)
+
+	a.Set("tiered_resub_key", "v2")
+	waitValue(t, b, "tiered_resub_key", "v2")
+}
+
+func TestTieredCacheL1TTLOfL2(t *testing.T) {
+	a, b := newTestTieredCache(t), newTestTieredCache(t)
+	defer a.Delete("tiered_ttl_key")
+
+	if err := a.SetWithTTL("tiered_ttl_key", 100*time.Millisecond, "short"); err != nil {
+		t.Fatal(err)
+	}
+	waitValue(t, b, "tiered_ttl_key", "short")
+	time.Sleep(150 * time.Millisecond)
+	var out string
+	if err := b.Get("tiered_ttl_key", &out); err == nil {
+		t.Errorf("value kept in L1 after its L2 ttl: %q", out)
+	}
+
+	// The negative payloads are kept for the negative ttl of the caller.
+	var calls int
+	notFound := func() (any, error) {
+		calls++
+		return nil, ErrNotFound
+	}
+	for i := 0; i < 2; i++ {
+		a.GetOrCreateWithTTL("tiered_ttl_key", time.Minute, notFound, &out, WithNegativeTTL(50*time.Millisecond))
+	}
+	if err := b.Get("tiered_ttl_key", &out); !errors.Is(err, ErrNotFound) {
+		t.Errorf("negative payload from L2 = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, tc := range []*TieredCache{a, b} {
+		if _, ok := tc.l1.peek("tiered_ttl_key"); ok {
+			t.Errorf("negative payload kept in L1 after the negative ttl")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("creator called %d times", calls)
+	}
+}
+
+func TestTieredCacheStaleWhileRevalidate(t *testing.T) {
+	tc := newTestTieredCache(t)
+	defer tc.Delete("tiered_swr_key")
+	testStaleWhileRevalidate(t, tc, "tiered_swr_key")
+}
+
+func TestTieredCacheRefreshAhead(t *testing.T) {
+	a, b := newTestTieredCache(t), newTestTieredCache(t)
+	defer a.Delete("tiered_ahead_key")
+	time.Sleep(50 * time.Millisecond)
+
+	var calls atomic.Int32
+	creator := func() (any, error) {
+		return int(calls.Add(1)), nil
+	}
+	get := func(tc *TieredCache) int {
+		var out int
+		if err := tc.GetOrCreateWithTTL("tiered_ahead_key", time.Second, creator, &out, WithRefreshAhead(0.5)); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	if get(a) != 1 || get(b) != 1 {
+		t.Fatalf("created value not shared")
+	}
+
+	// In the last half of its ttl, the value read from L1 is refreshed.
+	time.Sleep(600 * time.Millisecond)
+	if v := get(b); v != 1 {
+		t.Errorf("value before the refresh = %d, want 1", v)
+	}
+	// Before the hard ttl, which would recreate the value.
+	for i := 0; i < 40 && calls.Load() < 2; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("value not refreshed ahead")
+	}
+	time.Sleep(50 * time.Millisecond)
+	for _, tc := range []*TieredCache{a, b} {
+		if v := get(tc); v != 2 {
+			t.Errorf("value after the refresh = %d, want 2", v)
+		}
+	}
+}
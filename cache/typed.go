@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// valueStore is implemented by the caches keeping the values as they are set.
+type valueStore interface {
+	getValue(key string) (any, bool, error)
+	setValue(key string, value any, ttl time.Duration) error
+}
+
+// byteStore is implemented by the caches keeping the values encoded.
+type byteStore interface {
+	encode(p payload, ttl time.Duration) ([]byte, error)
+	decode(data []byte) (payload, error)
+	getBytes(key string) ([]byte, error)
+	setBytes(key string, data []byte, ttl time.Duration) error
+	fillBytes(key string, load func() ([]byte, time.Duration, error)) ([]byte, error)
+}
+
+// Typed is a typed facade of a Cache. MemoryCache keeps the values of type T
+// as they are and RedisCache encodes them with its codec, so that types like
+// time.Time and nested pointers survive a round trip.
+type Typed[T any] struct {
+	cache Cache
+	ttl   time.Duration
+	fills singleflight.Group
+}
+
+type TypedOption func(*typedOptions)
+
+type typedOptions struct {
+	ttl time.Duration
+}
+
+// WithTTL sets the ttl of the values set by Set and GetOrLoad, none by default.
+func WithTTL(ttl time.Duration) TypedOption {
+	return func(o *typedOptions) {
+		o.ttl = ttl
+	}
+}
+
+// NewTyped returns a typed facade of c, e.g.
+//
+//	users := cache.NewTyped[*User](cache.NewRedisCache(pool, cache.WithPrefix("user")))
+//	user, err := users.GetOrLoad(ctx, id, loadUser)
+func NewTyped[T any](c Cache, opts ...TypedOption) *Typed[T] {
+	o := &typedOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Typed[T]{cache: c, ttl: o.ttl}
+}
+
+// Get returns the value of key and whether it was found.
+func (t *Typed[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, false, err
+	}
+
+	switch c := t.cache.(type) {
+	case valueStore:
+		v, ok, err := c.getValue(key)
+		if err != nil || !ok {
+			return zero, false, err
+		}
+		value, ok := v.(T)
+		if !ok {
+			return zero, false, errors.Errorf("cache: value of %s is a %T", key, v)
+		}
+		return value, true, nil
+	case byteStore:
+		data, err := c.getBytes(key)
+		if err != nil || data == nil {
+			return zero, false, err
+		}
+		value, err := decodeValue[T](c, data)
+		if err != nil {
+			return zero, false, err
+		}
+		return value, true, nil
+	default:
+		var value T
+		if err := t.cache.Get(key, &value); errors.Is(err, ErrNotFound) {
+			return zero, false, nil
+		} else if err != nil {
+			return zero, false, err
+		}
+		return value, true, nil
+	}
+}
+
+func (t *Typed[T]) Set(ctx context.Context, key string, value T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	switch c := t.cache.(type) {
+	case valueStore:
+		return c.setValue(key, value, t.ttl)
+	case byteStore:
+		data, err := c.encode(payload{Content: value}, t.ttl)
+		if err != nil {
+			return errors.Wrap(err, "encode")
+		}
+		return c.setBytes(key, data, t.ttl)
+	default:
+		if ct, ok := t.cache.(CacheWithTTL); ok && t.ttl > 0 {
+			return ct.SetWithTTL(key, t.ttl, value)
+		}
+		return t.cache.Set(key, value)
+	}
+}
+
+// GetOrLoad returns the value of key, or else the one returned by loader,
+// which is set if loader succeeds. The concurrent misses of key call loader
+// once, with the context of the first one, and RedisCache applies its fill
+// lock.
+func (t *Typed[T]) GetOrLoad(ctx context.Context, key string, loader func(ctx context.Context) (T, error)) (T, error) {
+	value, ok, err := t.Get(ctx, key)
+	if err != nil || ok {
+		return value, err
+	}
+
+	v, err, _ := t.fills.Do(key, func() (any, error) {
+		if c, ok := t.cache.(byteStore); ok {
+			return t.fillBytes(ctx, c, key, loader)
+		}
+
+		value, ok, err := t.Get(ctx, key)
+		if err != nil || ok {
+			return value, err
+		}
+		value, err = loader(ctx)
+		if err != nil {
+			return value, err
+		}
+		return value, t.Set(ctx, key, value)
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	value, _ = v.(T)
+	return value, nil
+}
+
+func (t *Typed[T]) fillBytes(ctx context.Context, c byteStore, key string, loader func(ctx context.Context) (T, error)) (T, error) {
+	data, err := c.fillBytes(key, func() ([]byte, time.Duration, error) {
+		value, err := loader(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		data, err := c.encode(payload{Content: value}, t.ttl)
+		return data, t.ttl, errors.Wrap(err, "encode")
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decodeValue[T](c, data)
+}
+
+// decodeValue decodes the content of data into a T, so that the values set
+// by Typed can be read by Cache.Get and the other way round.
+func decodeValue[T any](c byteStore, data []byte) (T, error) {
+	var value T
+	p, err := c.decode(data)
+	if err != nil {
+		return value, errors.Wrap(err, "decode")
+	}
+	return value, p.Get(&value)
+}
+
+func (t *Typed[T]) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return t.cache.Delete(key)
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type typedData struct {
+	Name    string     `json:"name"`
+	Created time.Time  `json:"created"`
+	Parent  *typedData `json:"parent,omitempty"`
+}
+
+func TestTypedMemory(t *testing.T) {
+	mc := NewMemoryCache(time.Minute)
+	defer mc.Close()
+	typed := NewTyped[*typedData](mc)
+	ctx := context.Background()
+
+	if _, ok, err := typed.Get(ctx, "typed_key"); ok || err != nil {
+		t.Fatalf("Get of a missing key = %v, %v", ok, err)
+	}
+
+	value := &typedData{Name: "child", Created: time.Now(), Parent: &typedData{Name: "parent"}}
+	if err := typed.Set(ctx, "typed_key", value); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := typed.Get(ctx, "typed_key")
+	if err != nil || !ok {
+		t.Fatalf("Get = %v, %v", ok, err)
+	}
+	if got != value {
+		t.Errorf("MemoryCache should return the value as it was set")
+	}
+
+	if err := typed.Delete(ctx, "typed_key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := typed.Get(ctx, "typed_key"); ok {
+		t.Errorf("key exists after Delete")
+	}
+
+	if _, _, err := NewTyped[string](mc).Get(ctx, "typed_key_other"); err != nil {
+		t.Fatal(err)
+	}
+	mc.Set("typed_key_int", 1)
+	if _, _, err := NewTyped[string](mc).Get(ctx, "typed_key_int"); err == nil {
+		t.Errorf("Get of a value of another type should fail")
+	}
+}
+
+func TestTypedRedis(t *testing.T) {
+	typed := NewTyped[typedData](c, WithTTL(time.Minute))
+	ctx := context.Background()
+	defer typed.Delete(ctx, "typed_redis_key")
+
+	value := typedData{Name: "child", Created: time.Now().Round(0), Parent: &typedData{Name: "parent"}}
+	loads := 0
+	loader := func(context.Context) (typedData, error) {
+		loads++
+		return value, nil
+	}
+	for i := 0; i < 2; i++ {
+		got, err := typed.GetOrLoad(ctx, "typed_redis_key", loader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != value.Name || !got.Created.Equal(value.Created) || got.Parent == nil || got.Parent.Name != "parent" {
+			t.Errorf("GetOrLoad = %+v, want %+v", got, value)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loaded %d times, want 1", loads)
+	}
+
+	var untyped map[string]any
+	if err := c.Get("typed_redis_key", &untyped); err != nil || untyped["name"] != "child" {
+		t.Errorf("Cache.Get of a typed value = %v, %v", untyped, err)
+	}
+
+	loadErr := errors.New("load failed")
+	_, err := typed.GetOrLoad(ctx, "typed_redis_missing", func(context.Context) (typedData, error) {
+		return typedData{}, loadErr
+	})
+	if !errors.Is(err, loadErr) {
+		t.Errorf("GetOrLoad error = %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package dialer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/superwhys/goutils/lg"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// DefaultSlowThreshold is the duration above which a query is logged at
+// warn level by the gorm logger.
+const DefaultSlowThreshold = 200 * time.Millisecond
+
+// QueryStats are the counters of the queries traced by the gorm loggers
+// of an instance.
+type QueryStats struct {
+	Count  uint64
+	Errors uint64
+	Slow   uint64
+	Total  time.Duration
+	Max    time.Duration
+}
+
+type queryMetrics struct {
+	count  atomic.Uint64
+	errors atomic.Uint64
+	slow   atomic.Uint64
+	total  atomic.Int64
+	max    atomic.Int64
+}
+
+func (m *queryMetrics) record(elapsed time.Duration, slow, failed bool) {
+	m.count.Add(1)
+	if failed {
+		m.errors.Add(1)
+	}
+	if slow {
+		m.slow.Add(1)
+	}
+	m.total.Add(int64(elapsed))
+	for {
+		max := m.max.Load()
+		if int64(elapsed) <= max || m.max.CompareAndSwap(max, int64(elapsed)) {
+			return
+		}
+	}
+}
+
+func (m *queryMetrics) stats() QueryStats {
+	return QueryStats{
+		Count:  m.count.Load(),
+		Errors: m.errors.Load(),
+		Slow:   m.slow.Load(),
+		Total:  time.Duration(m.total.Load()),
+		Max:    time.Duration(m.max.Load()),
+	}
+}
+
+var (
+	metricsMu       sync.Mutex
+	instanceMetrics = map[string]*queryMetrics{}
+)
+
+func metricsOf(instance string) *queryMetrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	m, ok := instanceMetrics[instance]
+	if !ok {
+		m = &queryMetrics{}
+		instanceMetrics[instance] = m
+	}
+	return m
+}
+
+// GormQueryStats returns the query counters by instance, see WithGormInstance.
+func GormQueryStats() map[string]QueryStats {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	stats := make(map[string]QueryStats, len(instanceMetrics))
+	for instance, m := range instanceMetrics {
+		stats[instance] = m.stats()
+	}
+	return stats
+}
+
+// QueryObserver is called with every traced query, e.g. to feed a histogram.
+type QueryObserver func(instance string, elapsed time.Duration, err error)
+
+// GormLogger is a gorm logger writing through lg, with the LogContext of the
+// context of the query. The queries are logged at debug level, the slow ones
+// at warn level and the failed ones at error level.
+type GormLogger struct {
+	level          logger.LogLevel
+	instance       string
+	slowThreshold  time.Duration
+	redactParams   bool
+	ignoreNotFound bool
+	observer       QueryObserver
+	metrics        *queryMetrics
+}
+
+type GormLoggerOption func(*GormLogger)
+
+// WithGormInstance names the database of the lines and of the query stats.
+func WithGormInstance(instance string) GormLoggerOption {
+	return func(l *GormLogger) {
+		l.instance = instance
+	}
+}
+
+// WithSlowThreshold sets the duration above which a query is slow, 0 disables
+// the slow queries.
+func WithSlowThreshold(threshold time.Duration) GormLoggerOption {
+	return func(l *GormLogger) {
+		l.slowThreshold = threshold
+	}
+}
+
+// WithRedactParams logs the queries with the placeholders instead of the
+// values of their parameters.
+func WithRedactParams() GormLoggerOption {
+	return func(l *GormLogger) {
+		l.redactParams = true
+	}
+}
+
+// WithLogNotFound logs gorm.ErrRecordNotFound as an error, it is ignored by
+// default.
+func WithLogNotFound() GormLoggerOption {
+	return func(l *GormLogger) {
+		l.ignoreNotFound = false
+	}
+}
+
+func WithQueryObserver(observer QueryObserver) GormLoggerOption {
+	return func(l *GormLogger) {
+		l.observer = observer
+	}
+}
+
+func NewGormLogger(opts ...GormLoggerOption) *GormLogger {
+	l := &GormLogger{
+		level:          logger.Info,
+		slowThreshold:  DefaultSlowThreshold,
+		ignoreNotFound: true,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.metrics = metricsOf(l.instance)
+	return l
+}
+
+// Stats returns the counters of the queries of the instance of l.
+func (l *GormLogger) Stats() QueryStats {
+	return l.metrics.stats()
+}
+
+// LogMode returns a copy of l with the gorm level, e.g. logger.Silent for a
+// session without log. The level of the lines is still checked by lg.
+func (l *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+func (l *GormLogger) with(ctx context.Context) context.Context {
+	if l.instance != "" {
+		ctx = lg.With(ctx, "db=%s", l.instance)
+	}
+	return ctx
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Info {
+		lg.Infoc(l.with(ctx), msg, data...)
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Warn {
+		lg.Warnc(l.with(ctx), msg, data...)
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Error {
+		lg.Errorc(l.with(ctx), msg, data...)
+	}
+}
+
+// Trace logs the query and records its duration, which is done even if the
+// gorm level is silent.
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+	notFound := errors.Is(err, gorm.ErrRecordNotFound)
+	failed := err != nil && !(notFound && l.ignoreNotFound)
+	slow := l.slowThreshold > 0 && elapsed > l.slowThreshold
+
+	l.metrics.record(elapsed, slow, failed)
+	if l.observer != nil {
+		l.observer(l.instance, elapsed, err)
+	}
+
+	var level lg.Level
+	switch {
+	case failed && l.level >= logger.Error:
+		level = lg.ErrorLevel
+	case slow && l.level >= logger.Warn:
+		level = lg.WarnLevel
+	case l.level >= logger.Info:
+		level = lg.DebugLevel
+	default:
+		return
+	}
+	if !lg.Enabled(level) {
+		return
+	}
+
+	sql, rows := fc()
+	fields := []lg.Field{
+		lg.String("sql", sql),
+		lg.Duration("elapsed", elapsed),
+		lg.String("caller", utils.FileWithLineNum()),
+	}
+	if rows >= 0 {
+		fields = append(fields, lg.Int64("rows", rows))
+	}
+	msg := "gorm query"
+	switch level {
+	case lg.ErrorLevel:
+		fields = append(fields, lg.Err(err))
+	case lg.WarnLevel:
+		msg = fmt.Sprintf("gorm slow query >= %v", l.slowThreshold)
+	}
+	lg.LogFields(l.with(ctx), level, msg, fields...)
+}
+
+// ParamsFilter drops the parameters of the logged queries if they are
+// redacted, gorm then logs the placeholders.
+func (l *GormLogger) ParamsFilter(_ context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.redactParams {
+		return sql, nil
+	}
+	return sql, params
+}
@@ -0,0 +1,75 @@
+package dialer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/superwhys/goutils/lg"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestGormLogger(t *testing.T) {
+	var buf bytes.Buffer
+	lg.SetDefaultLoggerOutput(&buf, &buf)
+	defer lg.SetDefaultLoggerOutput(os.Stdout, os.Stderr)
+	defer lg.SetLevel(lg.GetLevel())
+	lg.SetLevel(lg.InfoLevel)
+
+	l := NewGormLogger(WithGormInstance("test-gorm-logger"), WithSlowThreshold(time.Second))
+	fc := func() (string, int64) { return "SELECT 1", 1 }
+	ctx := lg.With(context.Background(), "req=%s", "r1")
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		begin time.Time
+		err   error
+		want  []string
+	}{
+		{"fast", now, nil, nil},
+		{"not found", now, gorm.ErrRecordNotFound, nil},
+		{"slow", now.Add(-2 * time.Second), nil, []string{"[WARN]", "gorm slow query >= 1s", `sql="SELECT 1"`, "req=r1", "db=test-gorm-logger"}},
+		{"error", now, errors.New("broken"), []string{"[ERROR]", "gorm query", "error=broken", "rows=1"}},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		l.Trace(ctx, tt.begin, fc, tt.err)
+		got := buf.String()
+		if tt.want == nil && got != "" {
+			t.Errorf("%s: unexpected line %q", tt.name, got)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("%s: line %q does not contain %q", tt.name, got, w)
+			}
+		}
+	}
+
+	buf.Reset()
+	l.LogMode(logger.Silent).Trace(ctx, now.Add(-2*time.Second), fc, nil)
+	if buf.Len() != 0 {
+		t.Errorf("silent logger wrote %q", buf.String())
+	}
+
+	stats := GormQueryStats()["test-gorm-logger"]
+	if stats.Count != 5 || stats.Errors != 1 || stats.Slow != 2 || stats.Max < 2*time.Second {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestGormLoggerParamsFilter(t *testing.T) {
+	sql, params := NewGormLogger().ParamsFilter(context.Background(), "SELECT ?", "secret")
+	if sql != "SELECT ?" || len(params) != 1 {
+		t.Errorf("params should be kept, got %v", params)
+	}
+	_, params = NewGormLogger(WithRedactParams()).ParamsFilter(context.Background(), "SELECT ?", "secret")
+	if params != nil {
+		t.Errorf("params should be redacted, got %v", params)
+	}
+}
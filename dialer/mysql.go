@@ -59,16 +59,25 @@ func packDialOption(opts ...OptionFunc) *DialOption {
 	return opt
 }
 
-func generateDSN(address string, opts ...OptionFunc) string {
-	opt := packDialOption(opts...)
-	dsn := fmt.Sprintf(
+func formatDSN(address, user, password, dbName string) string {
+	return fmt.Sprintf(
 		"%v:%v@tcp(%v)/%v?charset=utf8mb4&parseTime=True&loc=Local",
-		opt.User,
-		opt.Password,
+		user,
+		password,
 		address,
-		opt.DBName,
+		dbName,
 	)
-	lg.Debugf("gorm dial dsn: %v", dsn)
+}
+
+func generateDSN(address string, opts ...OptionFunc) string {
+	opt := packDialOption(opts...)
+	dsn := formatDSN(address, opt.User, opt.Password, opt.DBName)
+
+	password := opt.Password
+	if password != "" {
+		password = lg.Redacted
+	}
+	lg.Debugf("gorm dial dsn: %v", formatDSN(address, opt.User, password, opt.DBName))
 	return dsn
 }
 
@@ -82,9 +91,15 @@ func DialGorm(service string, opts ...OptionFunc) (*gorm.DB, error) {
 	address := finder.GetServiceFinder().GetAddress(service)
 	lg.Debugf("Discover mysql addr: %v", address)
 
+	opt := packDialOption(opts...)
+	if opt.Logger == nil {
+		opt.Logger = NewGormLogger(WithGormInstance(service))
+	}
+
 	dsn := generateDSN(address, opts...)
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 		PrepareStmt: true,
+		Logger:      opt.Logger,
 	})
 	if err != nil {
 		return nil, err
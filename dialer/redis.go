@@ -9,14 +9,25 @@ import (
 )
 
 func DialRedisPool(addr string, db int, maxIdle int, password ...string) *redis.Pool {
+	var pwd string
+	if len(password) > 0 {
+		pwd = password[0]
+	}
+	return DialRedisPoolWithPasswordFunc(addr, db, maxIdle, func() string { return pwd })
+}
+
+// DialRedisPoolWithPasswordFunc is like DialRedisPool, but the password is
+// fetched every time a new connection is dialed, so a rotated password is
+// picked up without restarting.
+func DialRedisPoolWithPasswordFunc(addr string, db int, maxIdle int, password func() string) *redis.Pool {
 	return &redis.Pool{
 		MaxIdle:     maxIdle,
 		IdleTimeout: 300 * time.Second,
-		Dial:        consulRedisDial(addr, db, password...),
+		Dial:        consulRedisDial(addr, db, password),
 	}
 }
 
-func consulRedisDial(addr string, db int, password ...string) func() (redis.Conn, error) {
+func consulRedisDial(addr string, db int, password func() string) func() (redis.Conn, error) {
 	return func() (redis.Conn, error) {
 		var serviceAddr string
 		serviceAddr = finder.GetServiceFinder().GetAddress(addr)
@@ -30,8 +41,8 @@ func consulRedisDial(addr string, db int, password ...string) func() (redis.Conn
 			redis.DialConnectTimeout(5 * time.Second),
 		}
 
-		if len(password) > 0 && password[0] != "" {
-			options = append(options, redis.DialPassword(password[0]))
+		if pwd := password(); pwd != "" {
+			options = append(options, redis.DialPassword(pwd))
 		}
 
 		return redis.Dial("tcp", serviceAddr, options...)
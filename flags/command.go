@@ -0,0 +1,262 @@
+package flags
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/superwhys/goutils/lg"
+	"github.com/superwhys/goutils/slowinit"
+)
+
+// Command is a subcommand of a multi-tool binary, e.g. `tool migrate up`.
+// Flags are registered on a command with the same methods as the package
+// level functions, and are bound to viper and env once the command is
+// selected. The flags of a command are inherited by its subcommands, and
+// the global flags are accepted by every command.
+// example:
+//
+//	var (
+//		migrate = flags.NewCommand("migrate", "Database migrations")
+//		up      = migrate.NewCommand("up", "Apply the migrations")
+//		steps   = up.Int("steps", 0, "Number of migrations to apply, 0 means all")
+//	)
+//
+//	func main() {
+//		up.SetRun(runUp)
+//		flags.Execute()
+//	}
+type Command struct {
+	*flagScope
+
+	name     string
+	usage    string
+	run      func(args []string) error
+	parent   *Command
+	children []*Command
+}
+
+// commandError is returned by parsing when the selected command only groups its subcommands.
+type commandError struct {
+	cmd     *Command
+	unknown string
+}
+
+func (e *commandError) Error() string {
+	if e.unknown != "" {
+		return fmt.Sprintf("unknown command %q for %q", e.unknown, e.cmd.commandName())
+	}
+	return fmt.Sprintf("%q requires a subcommand", e.cmd.commandName())
+}
+
+// NewCommand adds a top level command.
+func NewCommand(name, usage string) *Command {
+	return defaultSet.NewCommand(name, usage)
+}
+
+// NewCommand adds a subcommand to c.
+func (c *Command) NewCommand(name, usage string) *Command {
+	if c.child(name) != nil {
+		lg.Fatal(fmt.Sprintf("Duplicate command: %s", strings.TrimSpace(c.Path()+" "+name)))
+	}
+	sub := &Command{
+		flagScope: &flagScope{set: c.set, fs: pflag.NewFlagSet(name, pflag.ContinueOnError), lazy: true},
+		name:      name,
+		usage:     usage,
+		parent:    c,
+	}
+	c.children = append(c.children, sub)
+	c.set.scopes = append(c.set.scopes, sub.flagScope)
+	return sub
+}
+
+// SetRun sets the function called by Execute with the positional args when
+// the command is selected. A command without run only groups its subcommands.
+func (c *Command) SetRun(run func(args []string) error) *Command {
+	c.run = run
+	return c
+}
+
+func (c *Command) Name() string {
+	return c.name
+}
+
+// Path returns the names of the command and its parents, e.g. "migrate up".
+func (c *Command) Path() string {
+	if c.parent == nil {
+		return ""
+	}
+	if p := c.parent.Path(); p != "" {
+		return p + " " + c.name
+	}
+	return c.name
+}
+
+// RegisterInit registers a slowinit object which is only initialized when
+// the command or one of its subcommands is selected.
+func (c *Command) RegisterInit(name string, fn func() error) {
+	slowinit.RegisterScopedObject(c.Path(), name, fn)
+}
+
+func (c *Command) child(name string) *Command {
+	for _, sub := range c.children {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+func (c *Command) commandName() string {
+	return strings.TrimSpace(c.set.name + " " + c.Path())
+}
+
+// Args returns the positional args left after the flags and the command names.
+func Args() []string {
+	return defaultSet.Args()
+}
+
+// Execute parses the command line like Parse does, then runs the selected command.
+func Execute() {
+	Parse()
+	cmd := defaultSet.selected[len(defaultSet.selected)-1]
+	if cmd.run == nil {
+		printHelp(os.Stderr, cmd)
+		os.Exit(2)
+	}
+	if err := cmd.run(defaultSet.commandArgs); err != nil {
+		lg.Fatal(fmt.Sprintf("%s: %v", cmd.commandName(), err))
+	}
+}
+
+// parseArgs parses the args with the flags of the command path they select.
+// errorHandling is used when the set has commands, otherwise the one of the
+// pflag set of the root applies.
+func (s *FlagSet) parseArgs(args []string, errorHandling pflag.ErrorHandling) error {
+	if len(s.root.children) == 0 {
+		if err := s.fs.Parse(args); err != nil {
+			return err
+		}
+		s.commandArgs = s.fs.Args()
+		return nil
+	}
+
+	s.addCompletionCommand()
+	s.selected = s.findCommands(args)
+	cmd := s.selected[len(s.selected)-1]
+
+	fs := pflag.NewFlagSet(s.name, errorHandling)
+	for _, c := range s.selected {
+		fs.AddFlagSet(c.fs)
+	}
+	fs.Usage = func() {
+		printHelp(os.Stderr, cmd)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s.commandLine = fs
+	// The command names are the first positional args.
+	s.commandArgs = fs.Args()[len(s.selected)-1:]
+	if cmd.run == nil && len(cmd.children) > 0 {
+		// A command only grouping its subcommands can not be run by itself.
+		err := &commandError{cmd: cmd}
+		if len(s.commandArgs) > 0 {
+			err.unknown = s.commandArgs[0]
+		}
+		return err
+	}
+	for _, c := range s.selected[1:] {
+		c.activate()
+	}
+	return nil
+}
+
+// findCommands returns the command path named by the leading positional args.
+func (s *FlagSet) findCommands(args []string) []*Command {
+	path := []*Command{s.root}
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	fs.AddFlagSet(s.root.fs)
+
+	cmd := s.root
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if strings.HasPrefix(arg, "-") {
+			if flagNeedsValue(fs, arg) {
+				i++
+			}
+			continue
+		}
+		sub := cmd.child(arg)
+		if sub == nil {
+			break
+		}
+		cmd = sub
+		path = append(path, cmd)
+		fs.AddFlagSet(cmd.fs)
+	}
+	return path
+}
+
+// flagNeedsValue reports whether the value of the flag is the next arg.
+func flagNeedsValue(fs *pflag.FlagSet, arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+
+	var f *pflag.Flag
+	if strings.HasPrefix(arg, "--") {
+		f = fs.Lookup(strings.TrimPrefix(arg, "--"))
+	} else if len(arg) == 2 {
+		f = fs.ShorthandLookup(arg[1:])
+	}
+	return f != nil && f.NoOptDefVal == ""
+}
+
+func printHelp(w io.Writer, cmd *Command) {
+	name := cmd.commandName()
+	fmt.Fprintf(w, "Usage:\n")
+	if cmd.run != nil {
+		fmt.Fprintf(w, "  %s [flags] [args]\n", name)
+	}
+	if len(cmd.children) > 0 {
+		fmt.Fprintf(w, "  %s [command]\n", name)
+	}
+	if cmd.usage != "" {
+		fmt.Fprintf(w, "\n%s\n", cmd.usage)
+	}
+
+	if len(cmd.children) > 0 {
+		width := 0
+		for _, sub := range cmd.children {
+			if len(sub.name) > width {
+				width = len(sub.name)
+			}
+		}
+		fmt.Fprintf(w, "\nCommands:\n")
+		for _, sub := range cmd.children {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, sub.name, sub.usage)
+		}
+	}
+
+	if cmd.fs.HasAvailableFlags() {
+		fmt.Fprintf(w, "\nFlags:\n%s", cmd.fs.FlagUsages())
+	}
+	inherited := pflag.NewFlagSet("", pflag.ContinueOnError)
+	for p := cmd.parent; p != nil; p = p.parent {
+		inherited.AddFlagSet(p.fs)
+	}
+	if inherited.HasAvailableFlags() {
+		fmt.Fprintf(w, "\nGlobal Flags:\n%s", inherited.FlagUsages())
+	}
+
+	if len(cmd.children) > 0 {
+		fmt.Fprintf(w, "\nUse \"%s [command] --help\" for more information about a command.\n", name)
+	}
+}
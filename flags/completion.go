@@ -0,0 +1,135 @@
+package flags
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	CompletionBash = "bash"
+	CompletionZsh  = "zsh"
+)
+
+var nonIdentRe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// addCompletionCommand adds the `completion` command unless the binary has its own.
+func (s *FlagSet) addCompletionCommand() {
+	if s.root.child("completion") != nil {
+		return
+	}
+	usage := fmt.Sprintf("Generate the completion script of bash or zsh, e.g. source <(%s completion bash)", s.name)
+	s.root.NewCommand("completion", usage).SetRun(func(args []string) error {
+		if len(args) != 1 {
+			return errors.New("expect exactly one shell: bash or zsh")
+		}
+		return s.GenCompletion(os.Stdout, args[0])
+	})
+}
+
+type completionCase struct {
+	path  string
+	words []string
+}
+
+// completionCases lists the words which can follow every command path,
+// which are its subcommands and all the flags it accepts.
+func (s *FlagSet) completionCases() []completionCase {
+	var cases []completionCase
+	var walk func(cmd *Command, path string, fs *pflag.FlagSet)
+	walk = func(cmd *Command, path string, fs *pflag.FlagSet) {
+		merged := pflag.NewFlagSet("", pflag.ContinueOnError)
+		merged.AddFlagSet(fs)
+		merged.AddFlagSet(cmd.fs)
+
+		var words []string
+		for _, sub := range cmd.children {
+			words = append(words, sub.name)
+		}
+		merged.VisitAll(func(f *pflag.Flag) {
+			if f.Hidden {
+				return
+			}
+			words = append(words, "--"+f.Name)
+		})
+		cases = append(cases, completionCase{path: path, words: words})
+
+		for _, sub := range cmd.children {
+			walk(sub, path+"/"+sub.name, merged)
+		}
+	}
+	walk(s.root, "", pflag.NewFlagSet("", pflag.ContinueOnError))
+	return cases
+}
+
+func writeCompletionBody(w *bytes.Buffer, cases []completionCase, assign func(words []string) string) {
+	var paths []string
+	for _, c := range cases[1:] {
+		paths = append(paths, fmt.Sprintf("%q", c.path))
+	}
+	if len(paths) > 0 {
+		fmt.Fprintf(w, "        case \"${cmdpath}/${word}\" in\n")
+		fmt.Fprintf(w, "            %s) cmdpath=\"${cmdpath}/${word}\" ;;\n", strings.Join(paths, "|"))
+		fmt.Fprintf(w, "        esac\n")
+	}
+	fmt.Fprintf(w, "    done\n")
+	fmt.Fprintf(w, "    case \"$cmdpath\" in\n")
+	for _, c := range cases {
+		fmt.Fprintf(w, "        %q) %s ;;\n", c.path, assign(c.words))
+	}
+	fmt.Fprintf(w, "    esac\n")
+}
+
+// GenCompletion writes the completion script of the command tree for the shell, bash or zsh.
+func GenCompletion(w io.Writer, shell string) error {
+	return defaultSet.GenCompletion(w, shell)
+}
+
+func (s *FlagSet) GenCompletion(w io.Writer, shell string) error {
+	prog := s.name
+	fn := "_" + nonIdentRe.ReplaceAllString(prog, "_") + "_completion"
+	cases := s.completionCases()
+
+	var buf bytes.Buffer
+	switch shell {
+	case CompletionBash:
+		fmt.Fprintf(&buf, "# bash completion for %s\n", prog)
+		fmt.Fprintf(&buf, "%s() {\n", fn)
+		fmt.Fprintf(&buf, "    local cur word cmdpath i opts\n")
+		fmt.Fprintf(&buf, "    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+		fmt.Fprintf(&buf, "    cmdpath=\"\"\n")
+		fmt.Fprintf(&buf, "    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+		fmt.Fprintf(&buf, "        word=\"${COMP_WORDS[i]}\"\n")
+		writeCompletionBody(&buf, cases, func(words []string) string {
+			return fmt.Sprintf("opts=%q", strings.Join(words, " "))
+		})
+		fmt.Fprintf(&buf, "    COMPREPLY=($(compgen -W \"$opts\" -- \"$cur\"))\n")
+		fmt.Fprintf(&buf, "}\n")
+		fmt.Fprintf(&buf, "complete -F %s %s\n", fn, prog)
+	case CompletionZsh:
+		fmt.Fprintf(&buf, "#compdef %s\n\n", prog)
+		fmt.Fprintf(&buf, "%s() {\n", fn)
+		fmt.Fprintf(&buf, "    local word cmdpath i\n")
+		fmt.Fprintf(&buf, "    local -a opts\n")
+		fmt.Fprintf(&buf, "    cmdpath=\"\"\n")
+		fmt.Fprintf(&buf, "    for ((i = 2; i < CURRENT; i++)); do\n")
+		fmt.Fprintf(&buf, "        word=\"${words[i]}\"\n")
+		writeCompletionBody(&buf, cases, func(words []string) string {
+			return fmt.Sprintf("opts=(%s)", strings.Join(words, " "))
+		})
+		fmt.Fprintf(&buf, "    compadd -- \"${opts[@]}\"\n")
+		fmt.Fprintf(&buf, "}\n\n")
+		fmt.Fprintf(&buf, "compdef %s %s\n", fn, prog)
+	default:
+		return fmt.Errorf("unsupported shell: %s", shell)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,58 @@
+package flags
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGenCompletion(t *testing.T) {
+	fs := NewFlagSet("tool")
+	fs.String("region", "eu", "region")
+	migrate := fs.NewCommand("migrate", "migrations")
+	migrate.String("dsn", "", "database")
+	up := migrate.NewCommand("up", "apply")
+	up.Int("steps", 0, "steps")
+	migrate.NewCommand("down", "revert")
+	fs.NewCommand("serve", "serve")
+
+	for _, tt := range []struct {
+		shell string
+		want  []string
+	}{
+		{CompletionBash, []string{
+			`complete -F _tool_completion tool`,
+			`"/migrate"|"/migrate/up"|"/migrate/down"|"/serve") cmdpath="${cmdpath}/${word}"`,
+			`"") opts="migrate serve `,
+			`"/migrate") opts="up down --dsn `,
+			`"/migrate/up") opts="--dsn `,
+		}},
+		{CompletionZsh, []string{
+			`compdef _tool_completion tool`,
+			`"/migrate"|"/migrate/up"|"/migrate/down"|"/serve") cmdpath="${cmdpath}/${word}"`,
+			`"") opts=(migrate serve `,
+			`"/migrate") opts=(up down --dsn `,
+			`"/migrate/up") opts=(--dsn `,
+		}},
+	} {
+		var buf bytes.Buffer
+		if err := fs.GenCompletion(&buf, tt.shell); err != nil {
+			t.Fatalf("%s: %v", tt.shell, err)
+		}
+		out := buf.String()
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s completion misses %q:\n%s", tt.shell, want, out)
+			}
+		}
+		for _, flag := range []string{"--region", "--steps"} {
+			if !strings.Contains(out, flag) {
+				t.Errorf("%s completion misses %s", tt.shell, flag)
+			}
+		}
+	}
+
+	if err := fs.GenCompletion(&bytes.Buffer{}, "fish"); err == nil {
+		t.Errorf("fish completion generated")
+	}
+}
@@ -0,0 +1,307 @@
+package flags
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/superwhys/goutils/lg"
+)
+
+const (
+	// includeKey lists the files to be loaded before the file declaring it.
+	// Relative paths are resolved against the directory of that file.
+	includeKey = "include"
+)
+
+var (
+	envVarRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+)
+
+// profileConfigFile returns the overlay file of the profile,
+// e.g. config.yaml with profile prod -> config.prod.yaml
+func profileConfigFile(file, profile string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + profile + ext
+}
+
+// interpolateEnv replaces ${ENV_VAR} and ${ENV_VAR:-default} in the string
+// values of the parsed config with the value of the env var, so that the env
+// values need no escaping. The default is used when the env var is unset or empty.
+func interpolateEnv(val interface{}) interface{} {
+	switch vv := val.(type) {
+	case string:
+		return envVarRe.ReplaceAllStringFunc(vv, func(match string) string {
+			groups := envVarRe.FindStringSubmatch(match)
+			if env := os.Getenv(groups[1]); env != "" {
+				return env
+			}
+			return groups[3]
+		})
+	case map[string]interface{}:
+		for k, v := range vv {
+			vv[k] = interpolateEnv(v)
+		}
+	case []interface{}:
+		for i, v := range vv {
+			vv[i] = interpolateEnv(v)
+		}
+	}
+	return val
+}
+
+// profileError is the error of reading the overlay of a profile.
+type profileError struct {
+	profile string
+	err     error
+}
+
+func (e *profileError) Error() string {
+	return fmt.Sprintf("profile %s: %v", e.profile, e.err)
+}
+
+func (e *profileError) Unwrap() error {
+	return e.err
+}
+
+// mergeSettings deep merges src into dst. Maps are merged key by key,
+// while any other value, lists included, in src replaces the one in dst.
+func mergeSettings(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = map[string]interface{}{}
+	}
+	for k, sv := range src {
+		srcMap, ok := sv.(map[string]interface{})
+		if !ok {
+			dst[k] = sv
+			continue
+		}
+		dstMap, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dstMap = nil
+		}
+		dst[k] = mergeSettings(dstMap, srcMap)
+	}
+	return dst
+}
+
+func includedFiles(file string, val interface{}) ([]string, error) {
+	var files []string
+	switch vv := val.(type) {
+	case string:
+		files = []string{vv}
+	case []interface{}:
+		for _, f := range vv {
+			s, ok := f.(string)
+			if !ok {
+				return nil, errors.Errorf("%s: invalid %s: %v", file, includeKey, f)
+			}
+			files = append(files, s)
+		}
+	default:
+		return nil, errors.Errorf("%s: invalid %s: %v", file, includeKey, val)
+	}
+
+	for i, f := range files {
+		if !filepath.IsAbs(f) {
+			files[i] = filepath.Join(filepath.Dir(file), f)
+		}
+	}
+	return files, nil
+}
+
+type configLoader struct {
+	loading map[string]bool
+	files   []string
+}
+
+func (l *configLoader) load(file string) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if l.loading[abs] {
+		return nil, errors.Errorf("%s: circular %s", file, includeKey)
+	}
+	l.loading[abs] = true
+	defer delete(l.loading, abs)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	l.files = append(l.files, file)
+
+	fv := viper.New()
+	fv.SetConfigType(strings.TrimPrefix(filepath.Ext(file), "."))
+	if err := fv.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, errors.Wrap(err, file)
+	}
+	settings := interpolateEnv(fv.AllSettings()).(map[string]interface{})
+
+	include, ok := settings[includeKey]
+	if !ok {
+		return settings, nil
+	}
+	delete(settings, includeKey)
+
+	files, err := includedFiles(file, include)
+	if err != nil {
+		return nil, err
+	}
+	merged := map[string]interface{}{}
+	for _, f := range files {
+		s, err := l.load(f)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeSettings(merged, s)
+	}
+	return mergeSettings(merged, settings), nil
+}
+
+// loadConfig reads the config file with its includes, and overlays the
+// config file of the profile on it if a profile is given.
+func loadConfig(file, profile string) (map[string]interface{}, []string, error) {
+	l := &configLoader{loading: map[string]bool{}}
+	settings, err := l.load(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if profile != "" {
+		overlay, err := l.load(profileConfigFile(file, profile))
+		if err != nil {
+			return nil, nil, &profileError{profile: profile, err: err}
+		}
+		settings = mergeSettings(settings, overlay)
+	}
+	return settings, l.files, nil
+}
+
+// ReadConfigFile reads the config file with its includes, and the overlay
+// of the profile if it is not empty. The whole config of the set is
+// replaced by it. Nothing is done if file is empty.
+func (s *FlagSet) ReadConfigFile(file, profile string) error {
+	if file == "" {
+		return nil
+	}
+
+	settings, files, err := loadConfig(file, profile)
+	if err != nil {
+		return err
+	}
+	if err := s.SetConfig(settings); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.configFile = file
+	s.profile = profile
+	s.configFiles = files
+	s.mu.Unlock()
+	lg.Info(fmt.Sprintf("Read config from local file: %v!", strings.Join(files, ", ")))
+	return nil
+}
+
+// SetConfig replaces the config of the set, as if it was read from a config file.
+// It is safe to call while the values are read, e.g. by the config file watcher.
+func (s *FlagSet) SetConfig(config map[string]interface{}) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.v.SetConfigType("json")
+	err = s.v.ReadConfig(bytes.NewReader(data))
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	s.version.Add(1)
+	return nil
+}
+
+// watchConfigFile reloads the config when any of the files it is read from changes.
+func (s *FlagSet) watchConfigFile() {
+	s.mu.RLock()
+	files := s.configFiles
+	s.mu.RUnlock()
+	if len(files) == 0 {
+		lg.Warn("No config file to watch")
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		lg.Error("Watch config error:", err)
+		return
+	}
+
+	// Watch the directories rather than the files, so that files replaced
+	// by editors are still watched. The files mounted by k8s config maps are
+	// symlinks, updated by swapping a symlink of their directory: they are
+	// reloaded when the file they resolve to changes, as viper does.
+	watching := map[string]bool{}
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if watching[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			lg.Error("Watch config error:", err)
+			continue
+		}
+		watching[dir] = true
+	}
+
+	go func() {
+		// realFiles is only used by this goroutine.
+		realFiles := map[string]string{}
+		for _, f := range files {
+			realFiles[f], _ = filepath.EvalSymlinks(f)
+		}
+		for {
+			select {
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				changed := (e.Has(fsnotify.Write) || e.Has(fsnotify.Create)) && s.isConfigFile(e.Name)
+				for f, real := range realFiles {
+					if current, _ := filepath.EvalSymlinks(f); current != "" && current != real {
+						realFiles[f] = current
+						changed = true
+					}
+				}
+				if !changed {
+					continue
+				}
+				lg.Info(fmt.Sprintf("Config file changed: %v", e.Name))
+				s.ReloadConfig()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				lg.Error("Watch config error:", err)
+			}
+		}
+	}()
+}
+
+func (s *FlagSet) isConfigFile(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, f := range s.configFiles {
+		if filepath.Clean(f) == filepath.Clean(name) {
+			return true
+		}
+	}
+	return false
+}
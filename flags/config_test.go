@@ -0,0 +1,158 @@
+package flags
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type configConf struct {
+	Host   string
+	Port   int
+	Hosts  []string
+	Labels map[string]string
+	Note   string
+}
+
+func TestReadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		profile string
+		env     map[string]string
+		want    configConf
+		wantErr string
+	}{
+		{
+			name: "profile overlay",
+			files: map[string]string{
+				"config.yaml":      "app:\n  host: base\n  port: 80\n  labels:\n    env: dev\n    team: core\n",
+				"config.prod.yaml": "app:\n  host: prod\n  labels:\n    env: prod\n",
+			},
+			profile: "prod",
+			want:    configConf{Host: "prod", Port: 80, Labels: map[string]string{"env": "prod", "team": "core"}},
+		},
+		{
+			name: "missing overlay",
+			files: map[string]string{
+				"config.yaml": "app:\n  host: base\n",
+			},
+			profile: "staging",
+			wantErr: "profile staging",
+		},
+		{
+			name: "include",
+			files: map[string]string{
+				"config.yaml":      "include: common/base.yaml\napp:\n  port: 8080\n",
+				"common/base.yaml": "app:\n  host: common\n  port: 80\n",
+			},
+			want: configConf{Host: "common", Port: 8080},
+		},
+		{
+			name: "circular include",
+			files: map[string]string{
+				"config.yaml": "include: other.yaml\n",
+				"other.yaml":  "include: config.yaml\n",
+			},
+			wantErr: "circular include",
+		},
+		{
+			name: "maps merged and lists replaced",
+			files: map[string]string{
+				"config.yaml": "include: [a.yaml, b.yaml]\n",
+				"a.yaml":      "app:\n  hosts: [a1, a2]\n  labels:\n    a: \"1\"\n",
+				"b.yaml":      "app:\n  hosts: [b1]\n  labels:\n    b: \"2\"\n",
+			},
+			want: configConf{Hosts: []string{"b1"}, Labels: map[string]string{"a": "1", "b": "2"}},
+		},
+		{
+			name: "env interpolation",
+			files: map[string]string{
+				// The comment is not interpolated, and the values need no escaping.
+				"config.yaml": "# ${CONFIG_TEST_UNSET}\napp:\n  host: ${CONFIG_TEST_HOST}\n  port: ${CONFIG_TEST_UNSET:-9090}\n  note: ${CONFIG_TEST_NOTE}\n",
+			},
+			env:  map[string]string{"CONFIG_TEST_HOST": "env-host", "CONFIG_TEST_NOTE": "a: b #c"},
+			want: configConf{Host: "env-host", Port: 9090, Note: "a: b #c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			fs := NewFlagSet("test")
+			conf := StructOfIn(fs, "app", configConf{}, "app")
+			err := fs.ReadConfigFile(filepath.Join(dir, "config.yaml"), tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := fs.Parse(nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := conf(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("config = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReloadConfigConcurrent(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("name: first\napp:\n  host: first\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := NewFlagSet("test")
+	name := fs.String("name", "", "name")
+	conf := StructOfIn(fs, "app", configConf{}, "app")
+	if err := fs.ReadConfigFile(file, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("name: second\napp:\n  host: second\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			fs.ReloadConfig()
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		if got := name(); got != "first" && got != "second" {
+			t.Fatalf("name = %q", got)
+		}
+		if got := conf().Host; got != "first" && got != "second" {
+			t.Fatalf("host = %q", got)
+		}
+	}
+	<-done
+
+	if name() != "second" || conf().Host != "second" {
+		t.Errorf("config not reloaded: name = %q, host = %q", name(), conf().Host)
+	}
+}
@@ -0,0 +1,350 @@
+package flags
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	DumpYAML     = "yaml"
+	DumpJSON     = "json"
+	DumpTOML     = "toml"
+	DumpMarkdown = "markdown"
+)
+
+var (
+	// cliOnlyFlags are only meaningful on the command line and never
+	// appear in the config file.
+	cliOnlyFlags = map[string]bool{
+		"config":      true,
+		"dump-config": true,
+		"help":        true,
+		"profile":     true,
+		"watchConfig": true,
+	}
+)
+
+type dumpEntry struct {
+	key      string
+	typ      string
+	value    interface{}
+	defValue string
+	env      string
+	usage    string
+	required bool
+}
+
+type dumpNode struct {
+	name     string
+	entry    *dumpEntry
+	children []*dumpNode
+}
+
+func (n *dumpNode) child(name string) *dumpNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &dumpNode{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+func (n *dumpNode) isLeaf() bool {
+	return n.entry != nil && len(n.children) == 0
+}
+
+// DumpConfig writes every registered key with its current value, default,
+// type, env var name and description to w. format is one of yaml, json,
+// toml and markdown.
+func DumpConfig(w io.Writer, format string) error {
+	return defaultSet.DumpConfig(w, format)
+}
+
+func (s *FlagSet) DumpConfig(w io.Writer, format string) error {
+	entries := s.collectDumpEntries()
+
+	var buf bytes.Buffer
+	switch strings.ToLower(format) {
+	case DumpYAML, "yml":
+		dumpYAML(&buf, buildDumpTree(entries), 0)
+	case DumpJSON:
+		dumpJSON(&buf, buildDumpTree(entries), 0)
+		buf.WriteString("\n")
+	case DumpTOML:
+		dumpTOML(&buf, buildDumpTree(entries), "")
+	case DumpMarkdown, "md":
+		dumpMarkdown(&buf, entries)
+	default:
+		return fmt.Errorf("unsupported dump format: %s", format)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (s *FlagSet) collectDumpEntries() []*dumpEntry {
+	required := map[string]bool{}
+	for _, k := range s.requiredKey {
+		required[strings.ToLower(k)] = true
+	}
+
+	var entries []*dumpEntry
+	s.commandLine.VisitAll(func(f *pflag.Flag) {
+		if cliOnlyFlags[f.Name] {
+			return
+		}
+		val := s.get(f.Name)
+		if val == nil {
+			val = flagValue(f)
+		}
+		if s.isSecret(f.Name) {
+			val = redact(fmt.Sprint(val))
+		}
+		entries = append(entries, &dumpEntry{
+			key:      f.Name,
+			typ:      f.Value.Type(),
+			value:    val,
+			defValue: f.DefValue,
+			env:      envName(f.Name),
+			usage:    f.Usage,
+			required: required[strings.ToLower(f.Name)],
+		})
+	})
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	return entries
+}
+
+// flagValue returns the value held by f converted to a go value
+// which can be rendered according to the flag type.
+func flagValue(f *pflag.Flag) interface{} {
+	typ := f.Value.Type()
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		elemType := strings.TrimSuffix(typ, "Slice")
+		var res []interface{}
+		for _, s := range sv.GetSlice() {
+			res = append(res, parseScalar(s, elemType))
+		}
+		return res
+	}
+	if strings.HasPrefix(typ, "stringTo") {
+		if m, err := parseStringMap(f.Value.String()); err == nil {
+			return m
+		}
+	}
+	return parseScalar(f.Value.String(), typ)
+}
+
+func parseScalar(s, typ string) interface{} {
+	switch {
+	case typ == "bool":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case strings.HasPrefix(typ, "int"), strings.HasPrefix(typ, "uint"):
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+	case strings.HasPrefix(typ, "float"):
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+func buildDumpTree(entries []*dumpEntry) *dumpNode {
+	root := &dumpNode{}
+	for _, e := range entries {
+		n := root
+		for _, p := range strings.Split(e.key, ".") {
+			n = n.child(p)
+		}
+		n.entry = e
+	}
+	return root
+}
+
+func (e *dumpEntry) comments() []string {
+	var lines []string
+	desc := e.usage
+	if e.required {
+		desc = "(required) " + desc
+	}
+	lines = append(lines, fmt.Sprintf("%s (%s): %s", e.key, e.typ, desc))
+	lines = append(lines, fmt.Sprintf("env: %s, default: %s", e.env, e.defaultString()))
+	return lines
+}
+
+func (e *dumpEntry) defaultString() string {
+	if e.defValue == "" {
+		return `""`
+	}
+	return e.defValue
+}
+
+func quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// formatValue renders val as an inline value. The JSON flavour is valid
+// YAML as well, toml only differs in the way of writing maps.
+func formatValue(val interface{}, toml bool) string {
+	switch vv := val.(type) {
+	case nil:
+		return `""`
+	case string:
+		return quote(vv)
+	case time.Duration:
+		return quote(vv.String())
+	}
+
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%v", val)
+	case reflect.Slice, reflect.Array:
+		var items []string
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, formatValue(rv.Index(i).Interface(), toml))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		var items []string
+		for _, k := range keys {
+			name := fmt.Sprint(k.Interface())
+			item := formatValue(rv.MapIndex(k).Interface(), toml)
+			if toml {
+				items = append(items, fmt.Sprintf("%s = %s", tomlKey(name), item))
+			} else {
+				items = append(items, fmt.Sprintf("%s: %s", quote(name), item))
+			}
+		}
+		if toml {
+			return "{ " + strings.Join(items, ", ") + " }"
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return formatValue(nil, toml)
+		}
+		return formatValue(rv.Elem().Interface(), toml)
+	}
+	return quote(fmt.Sprint(val))
+}
+
+var bareKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(name string) string {
+	if bareKeyRe.MatchString(name) {
+		return name
+	}
+	return quote(name)
+}
+
+func writeComments(w *bytes.Buffer, indent string, e *dumpEntry) {
+	if e == nil {
+		return
+	}
+	for _, l := range e.comments() {
+		fmt.Fprintf(w, "%s# %s\n", indent, l)
+	}
+}
+
+func dumpYAML(w *bytes.Buffer, n *dumpNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, c := range n.children {
+		writeComments(w, indent, c.entry)
+		if c.isLeaf() {
+			fmt.Fprintf(w, "%s%s: %s\n", indent, c.name, formatValue(c.entry.value, false))
+			continue
+		}
+		fmt.Fprintf(w, "%s%s:\n", indent, c.name)
+		dumpYAML(w, c, depth+1)
+	}
+}
+
+func dumpJSON(w *bytes.Buffer, n *dumpNode, depth int) {
+	indent := strings.Repeat("  ", depth+1)
+	w.WriteString("{\n")
+	for i, c := range n.children {
+		fmt.Fprintf(w, "%s%s: ", indent, quote(c.name))
+		if c.isLeaf() {
+			w.WriteString(formatValue(c.entry.value, false))
+		} else {
+			dumpJSON(w, c, depth+1)
+		}
+		if i != len(n.children)-1 {
+			w.WriteString(",")
+		}
+		w.WriteString("\n")
+	}
+	w.WriteString(strings.Repeat("  ", depth) + "}")
+}
+
+func dumpTOML(w *bytes.Buffer, n *dumpNode, table string) {
+	// toml requires the plain keys of a table to be written before any sub table.
+	for _, c := range n.children {
+		if !c.isLeaf() {
+			continue
+		}
+		writeComments(w, "", c.entry)
+		fmt.Fprintf(w, "%s = %s\n", tomlKey(c.name), formatValue(c.entry.value, true))
+	}
+	for _, c := range n.children {
+		if c.isLeaf() {
+			continue
+		}
+		name := tomlKey(c.name)
+		if table != "" {
+			name = table + "." + name
+		}
+		fmt.Fprintf(w, "\n")
+		writeComments(w, "", c.entry)
+		fmt.Fprintf(w, "[%s]\n", name)
+		dumpTOML(w, c, name)
+	}
+}
+
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+func dumpMarkdown(w *bytes.Buffer, entries []*dumpEntry) {
+	w.WriteString("# Configuration reference\n\n")
+	w.WriteString("| Key | Type | Default | Env | Description |\n")
+	w.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, e := range entries {
+		desc := e.usage
+		if e.required {
+			desc = "**required** " + desc
+		}
+		fmt.Fprintf(
+			w, "| `%s` | %s | `%s` | `%s` | %s |\n",
+			e.key, e.typ, escapeMarkdown(e.defaultString()), e.env, escapeMarkdown(desc),
+		)
+	}
+}
@@ -0,0 +1,55 @@
+package flags
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type dumpDB struct {
+	Host    string            `desc:"db host"`
+	Ports   []int             `desc:"db ports"`
+	Options map[string]string `desc:"db options"`
+}
+
+type dumpConf struct {
+	DB      dumpDB
+	Verbose bool `desc:"verbose | loud"`
+}
+
+func TestDumpConfig(t *testing.T) {
+	fs := NewFlagSet("dump")
+	fs.String("serviceName", "api", "name of the service")
+	fs.StringRequired("api-key", "key of the api")
+	fs.Slice("tags", []string{"a", "b"}, "tags")
+	StructOfIn(fs, "app", dumpConf{
+		DB: dumpDB{Host: "localhost", Ports: []int{3306}, Options: map[string]string{"charset": "utf8"}},
+	}, "app")
+	if err := fs.Parse([]string{"--api-key", "k"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for format, golden := range map[string]string{
+		DumpYAML:     "dump.yaml",
+		DumpJSON:     "dump.json",
+		DumpTOML:     "dump.toml",
+		DumpMarkdown: "dump.md",
+	} {
+		want, err := os.ReadFile(filepath.Join("testdata", golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := fs.DumpConfig(&buf, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if buf.String() != string(want) {
+			t.Errorf("%s dump:\n%s\nwant:\n%s", format, buf.String(), want)
+		}
+	}
+
+	if err := fs.DumpConfig(&bytes.Buffer{}, "xml"); err == nil {
+		t.Error("want error of unsupported format")
+	}
+}
@@ -4,11 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"reflect"
+	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/superwhys/goutils/internal/shared"
@@ -24,18 +24,21 @@ import (
 )
 
 var (
-	allKeys           []string
-	requiredKey       []string
-	nestedKey         = map[string]interface{}{}
 	config            *string
 	defaultConfigFile string
 	debug             *bool
 	useConsul         *bool
+	dumpConfig        *string
+	watchConfig       *bool
+	profile           *string
 
-	v = viper.New()
+	// defaultSet is bound to the command line of the process,
+	// the package level functions work on it.
+	defaultSet = newFlagSet(filepath.Base(os.Args[0]), pflag.CommandLine)
 
 	isLogToFile   = Bool("isLogToFile", false, "Whether write log to file.")
-	logConfigFlag = Struct("logConfig", &shared.LogConfig{}, "Log config")
+	logConfigFlag = StructOf("logConfig", shared.LogConfig{}, "Log config")
+	logLevel      = String("logLevel", "", "Log levels, the global one and package overrides, e.g. info,redisutils=debug")
 )
 
 func OverrideDefaultConfigFile(configFile string) {
@@ -43,25 +46,50 @@ func OverrideDefaultConfigFile(configFile string) {
 }
 
 func initFlags() {
-	v.AddConfigPath(".")
-	v.AddConfigPath("./tmp/config/")
+	s := defaultSet
+	s.v.AddConfigPath(".")
+	s.v.AddConfigPath("./tmp/config/")
 
-	shared.PtrServiceName = pflag.String("service", os.Getenv("SERVICE"), "Service name to access the config in remote consul KV store.")
-	shared.PtrConsulAddr = pflag.String("consulAddr", consul.HostAddress+":8500", "Consul address")
-	debug = pflag.Bool("debug", false, "Set true to enable debug mode")
-	useConsul = pflag.Bool("useConsul", true, "Whether to use the consul function")
+	shared.PtrServiceName = s.fs.String("service", os.Getenv("SERVICE"), "Service name to access the config in remote consul KV store.")
+	shared.PtrConsulAddr = s.fs.String("consulAddr", consul.HostAddress+":8500", "Consul address")
+	debug = s.fs.Bool("debug", false, "Set true to enable debug mode")
+	useConsul = s.fs.Bool("useConsul", true, "Whether to use the consul function")
 
-	err := v.BindPFlags(pflag.CommandLine)
+	err := s.v.BindPFlags(s.fs)
 	if err != nil {
 		lg.Fatal("BindPFlags Error!")
 	}
-	config = pflag.StringP("config", "f", defaultConfigFile, "Specify config file to parse. Support json, yaml, toml etc.")
+	config = s.fs.StringP("config", "f", defaultConfigFile, "Specify config file to parse. Support json, yaml, toml etc.")
+	dumpConfig = s.fs.String("dump-config", "", "Dump a documented config template in the given format (yaml, json, toml, markdown) and exit")
+	s.fs.Lookup("dump-config").NoOptDefVal = "yaml"
+	watchConfig = s.fs.Bool("watchConfig", false, "Reload the config file when it changes")
+	profile = s.fs.String("profile", os.Getenv("GOUTILS_PROFILE"), "Config profile to overlay on the config file, e.g. dev, staging, prod. Defaults to $GOUTILS_PROFILE")
+
+	for _, key := range []string{"debug", "service", "consulAddr", "useConsul"} {
+		s.bindEnv(key)
+	}
+	s.allKeys = append(s.allKeys, "debug", "service", "consulAddr", "useConsul")
+}
 
-	allKeys = append(allKeys, "debug", "service", "consulAddr", "useConsul")
+// envName returns the environment variable bound to the given key,
+// e.g. redisConf.Server -> REDISCONF_SERVER
+func envName(key string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+func (s *FlagSet) bindEnv(key string) {
+	if err := s.v.BindEnv(key, envName(key)); err != nil {
+		lg.Fatal(fmt.Sprintf("BindEnv err, Key: --%s", key))
+	}
 }
 
 func Viper() *viper.Viper {
-	return v
+	return defaultSet.Viper()
+}
+
+// CommandLine returns the default set, on which the package level functions work.
+func CommandLine() *FlagSet {
+	return defaultSet
 }
 
 func GetServiceName() string {
@@ -70,88 +98,123 @@ func GetServiceName() string {
 
 // Parse has to called after main() before any application code.
 func Parse() {
+	s := defaultSet
 	initFlags()
-	pflag.Parse()
+	if err := s.parseArgs(os.Args[1:], pflag.ExitOnError); err != nil {
+		var cmdErr *commandError
+		if errors.As(err, &cmdErr) {
+			if cmdErr.unknown != "" {
+				fmt.Fprintf(os.Stderr, "%v\n\n", cmdErr)
+			}
+			printHelp(os.Stderr, cmdErr.cmd)
+			os.Exit(2)
+		}
+		lg.Fatal(err)
+	}
 
 	if *debug {
 		lg.EnableDebug()
 	}
 
-	injectNestedKey()
-	readConfig()
-	checkFlagKey()
+	if *dumpConfig != "" {
+		// Keep stdout clean for the dumped config.
+		lg.SetDefaultLoggerOutput(os.Stderr, os.Stderr)
+	}
+	if err := s.ReadConfigFile(*config, *profile); err != nil {
+		// Running without the overlay of an explicit profile, e.g. on the
+		// defaults of dev with prod asked, is worse than not starting.
+		var profileErr *profileError
+		if errors.As(err, &profileErr) {
+			lg.Fatal(fmt.Sprintf("Failed to read on local file: %v", err))
+		}
+		lg.Error(fmt.Sprintf("Failed to read on local file: %v", err))
+	}
+	if *dumpConfig != "" {
+		if err := DumpConfig(os.Stdout, *dumpConfig); err != nil {
+			lg.Fatal("Dump config error:", err)
+		}
+		os.Exit(0)
+	}
+	if err := s.checkFlagKey(); err != nil {
+		lg.Fatal(err)
+	}
+	s.version.Add(1)
+	if err := s.runChecks(); err != nil {
+		lg.Fatal(err)
+	}
 	injectViperPflag()
+	if *watchConfig {
+		s.watchConfigFile()
+	}
 	slowinit.Init()
+	for _, cmd := range s.selected[1:] {
+		slowinit.InitScope(cmd.Path())
+	}
 }
 
-func injectNestedKey() {
-	for key, valuePtr := range nestedKey {
-		flag := pflag.Lookup(key)
-		if flag != nil && flag.Changed {
-			switch valuePtr.(type) {
-			case *int:
-				v.Set(key, *valuePtr.(*int))
-			case *bool:
-				v.Set(key, *valuePtr.(*bool))
-			case *float64:
-				v.Set(key, *valuePtr.(*float64))
-			case *time.Duration:
-				v.Set(key, *valuePtr.(*time.Duration))
-			case *string:
-				v.Set(key, *valuePtr.(*string))
-			case *[]bool:
-				v.Set(key, *valuePtr.(*[]bool))
-			case *[]string:
-				v.Set(key, *valuePtr.(*[]string))
-			case *[]int:
-				v.Set(key, *valuePtr.(*[]int))
-			case *[]float64:
-				v.Set(key, *valuePtr.(*[]float64))
-			case *[]time.Duration:
-				v.Set(key, *valuePtr.(*[]time.Duration))
-			default:
-				lg.Fatal("Unsupport flag value type", flag.Value.Type())
+// ReloadConfig reads the config file again. The values cached by StructOf
+// and Value are decoded again on their next call.
+func ReloadConfig() {
+	defaultSet.ReloadConfig()
+}
+
+func (s *FlagSet) runChecks() error {
+	for _, scope := range s.activeScopes {
+		for _, check := range scope.checks {
+			if err := check(); err != nil {
+				return err
 			}
 		}
 	}
+	return nil
 }
 
-func checkFlagKey() {
-	for _, k := range requiredKey {
-		if isZero(v.Get(k)) {
-			lg.Fatal("Missing", k)
+func (s *FlagSet) configReloaded() {
+	s.version.Add(1)
+	for _, scope := range s.activeScopes {
+		for _, check := range scope.checks {
+			if err := check(); err != nil {
+				lg.Error("Reload config:", err)
+			}
+		}
+	}
+}
+
+func (s *FlagSet) checkFlagKey() error {
+	for _, k := range s.requiredKey {
+		if isZero(s.get(k)) {
+			return fmt.Errorf("Missing %s", k)
 		}
 	}
 	expectedKeys := slices.NewStringSet(nil)
-	for _, k := range allKeys {
+	for _, k := range s.allKeys {
 		if err := expectedKeys.Add(strings.ToLower(k)); err != nil {
-			lg.Fatal(fmt.Sprintf("Add Key Error: --%s", k))
+			return fmt.Errorf("Add Key Error: --%s", k)
 		}
 	}
 
-	for _, k := range v.AllKeys() {
+	s.mu.RLock()
+	keys := s.v.AllKeys()
+	s.mu.RUnlock()
+	for _, k := range keys {
 		if strings.Contains(k, ".") {
 			// Ignore nested key
 			continue
 		}
 		if !expectedKeys.Contains(k) {
-			lg.Fatal(fmt.Sprintf("Unknown flag in config: --%s", k))
+			return fmt.Errorf("Unknown flag in config: --%s", k)
 		}
 	}
+	return nil
 }
 
-func readConfig() {
-	if config != nil && *config != "" {
-		v.SetConfigFile(*config)
-		if err := v.ReadInConfig(); err != nil {
-			lg.Error(fmt.Sprintf("Failed to read on local file: %v", err))
-		} else {
-			lg.Info(fmt.Sprintf("Read config from local file: %v!", *config))
+func injectViperPflag() {
+	v := defaultSet.v
+	if spec := logLevel(); spec != "" {
+		if err := lg.SetLevelSpec(spec); err != nil {
+			lg.Fatal(err)
 		}
 	}
-}
-
-func injectViperPflag() {
 	if v.GetBool("debug") {
 		lg.EnableDebug()
 	}
@@ -169,10 +232,24 @@ func injectViperPflag() {
 		*shared.PtrServiceName = srv
 	}
 
+	logConf := logConfigFlag()
+	if format, err := lg.ParseFormat(logConf.Format); err == nil {
+		lg.SetDefaultLoggerFormat(format)
+	}
+	if logConf.AsyncSize > 0 {
+		if policy, err := lg.ParseAsyncPolicy(logConf.AsyncPolicy); err == nil {
+			lg.EnableAsync(logConf.AsyncSize, policy)
+		}
+	}
+	if logConf.SampleFirst > 0 {
+		lg.SetSampling(&lg.Sampling{
+			First:      logConf.SampleFirst,
+			Thereafter: logConf.SampleThereafter,
+			Interval:   logConf.SampleInterval,
+		})
+	}
 	if isLogToFile() {
-		logConf := &shared.LogConfig{}
-		lg.PanicError(logConfigFlag(logConf))
-		lg.EnableLogToFile(logConf)
+		lg.EnableLogToFile(&logConf)
 	}
 }
 
@@ -198,112 +275,202 @@ func isZero(i interface{}) bool {
 	}
 }
 
-func String(key, defaultValue, usage string) func() string {
-	pflag.String(key, defaultValue, usage)
-	v.SetDefault(key, defaultValue)
-	err := v.BindPFlag(key, pflag.Lookup(key))
-	if err != nil {
-		lg.Fatal(fmt.Sprintf("BindPFlag err, Key: --%s", key))
+// flagScope is where flags are registered, either the root of a FlagSet
+// or a subcommand. The flags of a subcommand are only bound to viper when
+// the subcommand is selected, so that sibling commands can use the same key.
+type flagScope struct {
+	set  *FlagSet
+	fs   *pflag.FlagSet
+	lazy bool
+
+	// regs are the registrations of the flags, which are run again
+	// on a fresh pflag set and viper by Reset.
+	regs  []func()
+	binds []func()
+	// checks are run at the end of Parse if the scope is active, so that an
+	// invalid config fails the process on start instead of at the first use.
+	checks []func() error
+}
+
+// Registrar is where flags are registered, a *FlagSet or a *Command.
+type Registrar interface {
+	scope() *flagScope
+}
+
+func (s *flagScope) scope() *flagScope {
+	return s
+}
+
+func (s *flagScope) register(fn func()) {
+	s.regs = append(s.regs, fn)
+	fn()
+}
+
+func (s *flagScope) bind(fn func()) {
+	if s.lazy {
+		s.binds = append(s.binds, fn)
+		return
 	}
-	allKeys = append(allKeys, key)
-	return func() string {
-		return v.GetString(key)
+	fn()
+}
+
+func (s *flagScope) activate() {
+	for _, fn := range s.binds {
+		fn()
 	}
+	s.binds = nil
+	s.set.activeScopes = append(s.set.activeScopes, s)
 }
 
-func StringRequired(key, usage string) func() string {
-	requiredKey = append(requiredKey, key)
-	allKeys = append(allKeys, key)
-	return String(key, "", usage)
+// bindKey binds the flag of key to viper. The key is always known, so that it
+// is accepted in the config file even if its command is not selected.
+func (s *flagScope) bindKey(key string, defaultValue interface{}) {
+	s.set.allKeys = append(s.set.allKeys, key)
+	s.bind(func() {
+		s.set.v.SetDefault(key, defaultValue)
+		err := s.set.v.BindPFlag(key, s.fs.Lookup(key))
+		if err != nil {
+			lg.Fatal(fmt.Sprintf("BindPFlag err, Key: --%s", key))
+		}
+		s.set.bindEnv(key)
+	})
 }
 
-func Bool(key string, defaultValue bool, usage string) func() bool {
-	pflag.Bool(key, defaultValue, usage)
-	v.SetDefault(key, defaultValue)
-	err := v.BindPFlag(key, pflag.Lookup(key))
-	if err != nil {
-		lg.Fatal(fmt.Sprintf("BindPFlag err, Key: --%s", key))
+func (s *flagScope) require(key string) {
+	s.register(func() {
+		s.bind(func() {
+			s.set.requiredKey = append(s.set.requiredKey, key)
+		})
+	})
+}
+
+func (s *flagScope) String(key, defaultValue, usage string) func() string {
+	s.register(func() {
+		s.fs.String(key, defaultValue, usage)
+		s.bindKey(key, defaultValue)
+	})
+	return func() string {
+		return cast.ToString(s.set.get(key))
 	}
-	allKeys = append(allKeys, key)
+}
+
+func (s *flagScope) StringRequired(key, usage string) func() string {
+	s.require(key)
+	return s.String(key, "", usage)
+}
+
+func (s *flagScope) Bool(key string, defaultValue bool, usage string) func() bool {
+	s.register(func() {
+		s.fs.Bool(key, defaultValue, usage)
+		s.bindKey(key, defaultValue)
+	})
 	return func() bool {
-		return v.GetBool(key)
+		return cast.ToBool(s.set.get(key))
 	}
 }
 
-func BoolRequired(key, usage string) func() bool {
-	requiredKey = append(requiredKey, key)
-	allKeys = append(allKeys, key)
-	return Bool(key, false, usage)
+func (s *flagScope) BoolRequired(key, usage string) func() bool {
+	s.require(key)
+	return s.Bool(key, false, usage)
 }
 
-func Int(key string, defaultValue int, usage string) func() int {
-	pflag.Int(key, defaultValue, usage)
-	v.SetDefault(key, defaultValue)
-	err := v.BindPFlag(key, pflag.Lookup(key))
-	if err != nil {
-		lg.Fatal(fmt.Sprintf("BindPFlag err, Key: --%s", key))
-	}
-	allKeys = append(allKeys, key)
+func (s *flagScope) Int(key string, defaultValue int, usage string) func() int {
+	s.register(func() {
+		s.fs.Int(key, defaultValue, usage)
+		s.bindKey(key, defaultValue)
+	})
 	return func() int {
-		return v.GetInt(key)
+		return cast.ToInt(s.set.get(key))
 	}
 }
 
-func IntRequired(key, usage string) func() int {
-	requiredKey = append(requiredKey, key)
-	allKeys = append(allKeys, key)
-	return Int(key, 0, usage)
+func (s *flagScope) IntRequired(key, usage string) func() int {
+	s.require(key)
+	return s.Int(key, 0, usage)
 }
 
-func Slice(key string, defaultValue []string, usage string) func() []string {
-	pflag.StringSlice(key, defaultValue, usage)
-	v.SetDefault(key, defaultValue)
-	err := v.BindPFlag(key, pflag.Lookup(key))
-	if err != nil {
-		lg.Fatal(fmt.Sprintf("BindPFlag err, Key: --%s", key))
-	}
-	allKeys = append(allKeys, key)
+func (s *flagScope) Slice(key string, defaultValue []string, usage string) func() []string {
+	s.register(func() {
+		s.fs.StringSlice(key, defaultValue, usage)
+		s.bindKey(key, defaultValue)
+	})
 	return func() []string {
-		return v.GetStringSlice(key)
+		return cast.ToStringSlice(s.set.get(key))
 	}
 }
 
-func Float64(key string, defaultValue float64, usage string) func() float64 {
-	pflag.Float64(key, defaultValue, usage)
-	v.SetDefault(key, defaultValue)
-	err := v.BindPFlag(key, pflag.Lookup(key))
-	if err != nil {
-		lg.Fatal(fmt.Sprintf("BindPFlag err, Key: --%s", key))
-	}
-	allKeys = append(allKeys, key)
+func (s *flagScope) Float64(key string, defaultValue float64, usage string) func() float64 {
+	s.register(func() {
+		s.fs.Float64(key, defaultValue, usage)
+		s.bindKey(key, defaultValue)
+	})
 	return func() float64 {
-		return v.GetFloat64(key)
+		return cast.ToFloat64(s.set.get(key))
 	}
 }
 
-func Float64Required(key, usage string) func() float64 {
-	requiredKey = append(requiredKey, key)
-	allKeys = append(allKeys, key)
-	return Float64(key, 0, usage)
+func (s *flagScope) Float64Required(key, usage string) func() float64 {
+	s.require(key)
+	return s.Float64(key, 0, usage)
 }
 
-func Duration(key string, defaultValue time.Duration, usage string) func() time.Duration {
-	pflag.Duration(key, defaultValue, usage)
-	v.SetDefault(key, defaultValue)
-	err := v.BindPFlag(key, pflag.Lookup(key))
-	if err != nil {
-		lg.Fatal(fmt.Sprintf("BindPFlag err, Key: --%s", key))
-	}
-	allKeys = append(allKeys, key)
+func (s *flagScope) Duration(key string, defaultValue time.Duration, usage string) func() time.Duration {
+	s.register(func() {
+		s.fs.Duration(key, defaultValue, usage)
+		s.bindKey(key, defaultValue)
+	})
 	return func() time.Duration {
-		return v.GetDuration(key)
+		return cast.ToDuration(s.set.get(key))
 	}
 }
 
+func (s *flagScope) DurationRequired(key, usage string) func() time.Duration {
+	s.require(key)
+	return s.Duration(key, 0, usage)
+}
+
+func String(key, defaultValue, usage string) func() string {
+	return defaultSet.String(key, defaultValue, usage)
+}
+
+func StringRequired(key, usage string) func() string {
+	return defaultSet.StringRequired(key, usage)
+}
+
+func Bool(key string, defaultValue bool, usage string) func() bool {
+	return defaultSet.Bool(key, defaultValue, usage)
+}
+
+func BoolRequired(key, usage string) func() bool {
+	return defaultSet.BoolRequired(key, usage)
+}
+
+func Int(key string, defaultValue int, usage string) func() int {
+	return defaultSet.Int(key, defaultValue, usage)
+}
+
+func IntRequired(key, usage string) func() int {
+	return defaultSet.IntRequired(key, usage)
+}
+
+func Slice(key string, defaultValue []string, usage string) func() []string {
+	return defaultSet.Slice(key, defaultValue, usage)
+}
+
+func Float64(key string, defaultValue float64, usage string) func() float64 {
+	return defaultSet.Float64(key, defaultValue, usage)
+}
+
+func Float64Required(key, usage string) func() float64 {
+	return defaultSet.Float64Required(key, usage)
+}
+
+func Duration(key string, defaultValue time.Duration, usage string) func() time.Duration {
+	return defaultSet.Duration(key, defaultValue, usage)
+}
+
 func DurationRequired(key, usage string) func() time.Duration {
-	requiredKey = append(requiredKey, key)
-	allKeys = append(allKeys, key)
-	return Duration(key, 0, usage)
+	return defaultSet.DurationRequired(key, usage)
 }
 
 type HasDefault interface {
@@ -320,22 +487,33 @@ type HasValidator interface {
 //		Struct("testKey", &TestConfig{}, "struct config")
 //	or  Struct("testKey", (*TestConfig)(nil), "struct config")
 //
-// the first example can add the field in help page, while the second example can not
+// Every field of the struct is registered as a flag named by its key path,
+// e.g. --testKey.Name. Non-zero fields of defaultValue become the defaults of
+// those flags. When decoding, SetDefault of HasDefault is applied first, and
+// values from config file, env and flags override it.
 func Struct(key string, defaultValue interface{}, usage string) func(out interface{}) error {
-	if err := setPFlagRecursively(key, defaultValue); err != nil {
-		lg.Debug("Ignore flag key", key, err)
-	}
+	return defaultSet.Struct(key, defaultValue, usage)
+}
 
-	v.SetDefault(key, defaultValue)
-	allKeys = append(allKeys, key)
-	return func(out interface{}) error {
-		if err := v.UnmarshalKey(key, out); err != nil {
-			return err
+func (s *flagScope) Struct(key string, defaultValue interface{}, usage string) func(out interface{}) error {
+	s.register(func() {
+		if err := s.setPFlagRecursively(key, defaultValue); err != nil {
+			lg.Debug("Ignore flag key", key, err)
 		}
+
+		s.set.allKeys = append(s.set.allKeys, key)
+		s.bind(func() {
+			s.set.v.SetDefault(key, defaultValue)
+		})
+	})
+	return func(out interface{}) error {
 		d, ok := out.(HasDefault)
 		if ok {
 			d.SetDefault()
 		}
+		if err := s.set.unmarshalKey(key, out); err != nil {
+			return err
+		}
 		v, ok := out.(HasValidator)
 		if ok {
 			return v.Validate()
@@ -343,76 +521,3 @@ func Struct(key string, defaultValue interface{}, usage string) func(out interfa
 		return nil
 	}
 }
-
-func setPFlag(key string, ptr interface{}) {
-	v.BindPFlag(key, pflag.Lookup(key))
-	nestedKey[key] = ptr
-}
-
-func setPFlagRecursively(prefix string, i interface{}) error {
-	vf := reflect.ValueOf(i)
-	if vf.Kind() == reflect.Ptr {
-		vf = vf.Elem()
-	}
-	if vf.Kind() != reflect.Struct {
-		return errors.New("not struct")
-	}
-	for i := 0; i < vf.NumField(); i++ {
-		field := vf.Type().Field(i)
-		name := field.Name
-		for _, tag := range []string{"flags", "flag", "json", "bson", "mapstructure"} {
-			if content := field.Tag.Get(tag); content != "" {
-				name = strings.SplitN(content, ",", 2)[0]
-				break
-			}
-		}
-		desc := field.Tag.Get("desc")
-		name = prefix + "." + name
-
-		switch vf.Field(i).Kind() {
-		case reflect.Bool:
-			setPFlag(name, pflag.Bool(name, vf.Field(i).Bool(), desc))
-		case reflect.Int, reflect.Int64:
-			if field.Type.String() == "time.Duration" {
-				setPFlag(name, pflag.Duration(name, time.Duration(vf.Field(i).Int()), desc))
-			} else {
-				setPFlag(name, pflag.Int(name, int(vf.Field(i).Int()), desc))
-			}
-		case reflect.Float64:
-			setPFlag(name, pflag.Float64(name, vf.Field(i).Float(), desc))
-		case reflect.String:
-			setPFlag(name, pflag.String(name, vf.Field(i).String(), desc))
-		case reflect.Slice:
-			switch field.Type.String() {
-			case "[]int":
-				setPFlag(name, pflag.IntSlice(name, vf.Field(i).Interface().([]int), desc))
-			case "[]string":
-				setPFlag(name, pflag.StringSlice(name, vf.Field(i).Interface().([]string), desc))
-			case "[]float64":
-				setPFlag(name, pflag.Float64Slice(name, vf.Field(i).Interface().([]float64), desc))
-			case "[]bool":
-				setPFlag(name, pflag.BoolSlice(name, vf.Field(i).Interface().([]bool), desc))
-			case "[]time.Duration":
-				setPFlag(name, pflag.DurationSlice(name, vf.Field(i).Interface().([]time.Duration), desc))
-			default:
-				return fmt.Errorf("Unsupport type of field %s %s", field.Name, field.Type.String())
-			}
-		case reflect.Struct, reflect.Ptr:
-			if err := setPFlagRecursively(name, vf.Field(i).Interface()); err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("Unsupport kind of field %s %s", field.Name, vf.Field(i).Kind())
-		}
-	}
-
-	return nil
-}
-
-func decodeHookFunc() mapstructure.DecodeHookFunc {
-	// We use lazy function init to decouple cycle dependency.
-	return mapstructure.ComposeDecodeHookFunc(
-		mapstructure.StringToTimeDurationHookFunc(),
-		mapstructure.StringToSliceHookFunc(","),
-	)
-}
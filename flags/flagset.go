@@ -0,0 +1,177 @@
+package flags
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/superwhys/goutils/lg"
+)
+
+// FlagSet is a set of flags with its own viper instance and pflag set, so
+// that it can be parsed apart from the command line of the process.
+// The package level functions work on a default FlagSet bound to pflag.CommandLine.
+// example:
+//
+//	fs := flags.NewFlagSet("tool")
+//	addr := fs.String("addr", ":8080", "listen address")
+//	conf := flags.StructOfIn(fs, "redisConf", RedisConf{}, "redis config")
+//	if err := fs.Parse(os.Args[1:]); err != nil {
+//		...
+//	}
+type FlagSet struct {
+	*flagScope
+
+	name string
+	root *Command
+	// scopes are the scopes of the root and all the commands.
+	scopes []*flagScope
+	// version is increased every time the config is (re)loaded,
+	// which invalidates the values cached by the typed accessors.
+	version atomic.Uint64
+	// mu guards the config of v and the files it is read from, which
+	// are replaced by SetConfig while the accessors read them.
+	mu sync.RWMutex
+
+	flagSetState
+}
+
+// flagSetState is built up by registering flags and parsing, and is
+// replaced by a fresh one by Reset.
+type flagSetState struct {
+	v           *viper.Viper
+	allKeys     []string
+	requiredKey []string
+	// leafDefaults holds the non-zero field values of the struct passed to Struct,
+	// keyed by the lower case key path of the field.
+	leafDefaults map[string]interface{}
+	// secretKeys are the lower case keys whose value must not be printed.
+	// The value reports whether the key is a plain string tagged with `secret:"true"`,
+	// whose file and env reference are resolved when decoding.
+	secretKeys map[string]bool
+
+	// selected is the command path chosen by the command line, the root first.
+	selected []*Command
+	// activeScopes are the root scope and the scopes of the selected commands.
+	activeScopes []*flagScope
+	// commandLine is the flag set the args are parsed with, holding
+	// the root flags and the flags of the selected commands.
+	commandLine *pflag.FlagSet
+	commandArgs []string
+
+	configFile string
+	profile    string
+	// configFiles are all the files the current config is read from.
+	configFiles []string
+}
+
+func NewFlagSet(name string) *FlagSet {
+	return newFlagSet(name, pflag.NewFlagSet(name, pflag.ContinueOnError))
+}
+
+func newFlagSet(name string, fs *pflag.FlagSet) *FlagSet {
+	s := &FlagSet{name: name}
+	s.flagScope = &flagScope{set: s, fs: fs}
+	s.root = &Command{flagScope: s.flagScope}
+	s.scopes = []*flagScope{s.flagScope}
+	s.flagSetState = s.freshState()
+	return s
+}
+
+func (s *FlagSet) freshState() flagSetState {
+	return flagSetState{
+		v:            viper.New(),
+		leafDefaults: map[string]interface{}{},
+		secretKeys:   map[string]bool{},
+		selected:     []*Command{s.root},
+		activeScopes: []*flagScope{s.flagScope},
+		commandLine:  s.fs,
+	}
+}
+
+// Viper returns the viper instance of the set. Reading it is not
+// synchronized with the config reloaded by the config file watcher.
+func (s *FlagSet) Viper() *viper.Viper {
+	return s.v
+}
+
+// get returns the value of key, under the read lock of the config.
+func (s *FlagSet) get(key string) interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v.Get(key)
+}
+
+// NewCommand adds a top level command to the set.
+func (s *FlagSet) NewCommand(name, usage string) *Command {
+	return s.root.NewCommand(name, usage)
+}
+
+// Args returns the positional args left after the flags and the command names.
+func (s *FlagSet) Args() []string {
+	return s.commandArgs
+}
+
+// Parse parses the args, then checks the required and unknown keys and
+// decodes the typed values. Unlike the package level Parse, the config
+// file should be read by ReadConfigFile or set by SetConfig beforehand,
+// and no slowinit object is initialized.
+func (s *FlagSet) Parse(args []string) error {
+	if err := s.parseArgs(args, pflag.ContinueOnError); err != nil {
+		return err
+	}
+	if err := s.checkFlagKey(); err != nil {
+		return err
+	}
+	s.version.Add(1)
+	return s.runChecks()
+}
+
+// ReloadConfig reads the config file again. The values cached by StructOf
+// and Value are decoded again on their next call.
+func (s *FlagSet) ReloadConfig() {
+	s.mu.RLock()
+	file, profile := s.configFile, s.profile
+	s.mu.RUnlock()
+	if err := s.ReadConfigFile(file, profile); err != nil {
+		lg.Error(fmt.Sprintf("Failed to read on local file: %v", err))
+		return
+	}
+	s.configReloaded()
+}
+
+// Reset replaces the state of the set with a fresh one, on which the flags
+// are registered again, e.g. to parse other args in a test. It returns the
+// function restoring the previous state. See the flagstest package.
+func (s *FlagSet) Reset() (restore func()) {
+	type scopeState struct {
+		fs    *pflag.FlagSet
+		binds []func()
+	}
+
+	saved := s.flagSetState
+	savedScopes := make([]scopeState, len(s.scopes))
+	for i, scope := range s.scopes {
+		savedScopes[i] = scopeState{fs: scope.fs, binds: scope.binds}
+		scope.fs = pflag.NewFlagSet(s.name, pflag.ContinueOnError)
+		scope.binds = nil
+	}
+	s.flagSetState = s.freshState()
+	for _, scope := range s.scopes {
+		for _, reg := range scope.regs {
+			reg()
+		}
+	}
+	s.version.Add(1)
+
+	return func() {
+		s.flagSetState = saved
+		for i, ss := range savedScopes {
+			s.scopes[i].fs = ss.fs
+			s.scopes[i].binds = ss.binds
+		}
+		s.version.Add(1)
+	}
+}
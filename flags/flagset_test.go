@@ -0,0 +1,139 @@
+package flags
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConf struct {
+	Addr     string
+	Port     int
+	Timeout  time.Duration
+	Password string `secret:"true"`
+}
+
+func (c *testConf) SetDefault() {
+	c.Port = 80
+}
+
+func (c *testConf) Validate() error {
+	if c.Addr == "" {
+		return errors.New("addr is empty")
+	}
+	return nil
+}
+
+func TestFlagSetParse(t *testing.T) {
+	fs := NewFlagSet("test")
+	name := fs.String("name", "default", "name")
+	count := fs.Int("count", 1, "count")
+	conf := StructOfIn(fs, "conf", testConf{Timeout: time.Second}, "conf")
+
+	err := fs.SetConfig(map[string]interface{}{
+		"name": "config",
+		"conf": map[string]interface{}{"addr": "localhost", "port": 8080},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"--count", "3", "--conf.Port=9090", "arg"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if name() != "config" {
+		t.Errorf("name: want config, got %v", name())
+	}
+	if count() != 3 {
+		t.Errorf("count: want 3, got %v", count())
+	}
+	want := testConf{Addr: "localhost", Port: 9090, Timeout: time.Second}
+	if conf() != want {
+		t.Errorf("conf: want %+v, got %+v", want, conf())
+	}
+	if args := fs.Args(); len(args) != 1 || args[0] != "arg" {
+		t.Errorf("args: want [arg], got %v", args)
+	}
+}
+
+func TestFlagSetParseError(t *testing.T) {
+	fs := NewFlagSet("test")
+	fs.StringRequired("name", "name")
+	if err := fs.Parse(nil); err == nil {
+		t.Error("want error of missing required key")
+	}
+
+	fs = NewFlagSet("test")
+	fs.String("name", "", "name")
+	if err := fs.SetConfig(map[string]interface{}{"unknown": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse(nil); err == nil {
+		t.Error("want error of unknown key in config")
+	}
+
+	fs = NewFlagSet("test")
+	StructOfIn(fs, "conf", testConf{}, "conf")
+	if err := fs.Parse(nil); err == nil {
+		t.Error("want error of validation")
+	}
+}
+
+func TestFlagSetCommands(t *testing.T) {
+	fs := NewFlagSet("tool")
+	verbose := fs.Bool("verbose", false, "verbose")
+	migrate := fs.NewCommand("migrate", "migrations")
+	up := migrate.NewCommand("up", "apply").SetRun(func(args []string) error { return nil })
+	down := migrate.NewCommand("down", "revert").SetRun(func(args []string) error { return nil })
+	upSteps := up.Int("steps", 0, "steps")
+	downSteps := down.Int("steps", 1, "steps")
+
+	if err := fs.Parse([]string{"--verbose", "migrate", "down", "--steps", "2", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if !verbose() {
+		t.Error("verbose: want true")
+	}
+	if downSteps() != 2 {
+		t.Errorf("down steps: want 2, got %v", downSteps())
+	}
+	if upSteps() != 2 {
+		// Sibling commands share the key in viper, only the selected one is bound.
+		t.Errorf("up steps: want the value of the selected command 2, got %v", upSteps())
+	}
+	if args := fs.Args(); len(args) != 1 || args[0] != "x" {
+		t.Errorf("args: want [x], got %v", args)
+	}
+	if got := fs.selected[len(fs.selected)-1]; got != down {
+		t.Errorf("selected: want down, got %v", got.Path())
+	}
+}
+
+func TestFlagSetUnknownCommand(t *testing.T) {
+	fs := NewFlagSet("tool")
+	fs.NewCommand("migrate", "migrations").NewCommand("up", "apply")
+
+	var cmdErr *commandError
+	err := fs.Parse([]string{"migrate", "sideways"})
+	if !errors.As(err, &cmdErr) || cmdErr.unknown != "sideways" {
+		t.Errorf("want unknown command error, got %v", err)
+	}
+}
+
+func TestDumpConfigRedactSecret(t *testing.T) {
+	fs := NewFlagSet("test")
+	StructOfIn(fs, "conf", testConf{Addr: "localhost"}, "conf")
+	if err := fs.Parse([]string{"--conf.Password", "pwd"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := fs.DumpConfig(&buf, DumpYAML); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "pwd") || !strings.Contains(buf.String(), Redacted) {
+		t.Errorf("password not redacted:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,32 @@
+// Package flagstest parses the flags of a flags.FlagSet in tests.
+package flagstest
+
+import (
+	"testing"
+
+	"github.com/superwhys/goutils/flags"
+)
+
+// ParseIn parses args and config, e.g. {"redisConf": {"server": "localhost:6379"}},
+// on a fresh state of fs, and restores the state when the test ends.
+// The functions returned by the flags registered on fs read the
+// parsed values meanwhile. It must not be used by parallel tests.
+func ParseIn(t testing.TB, fs *flags.FlagSet, args []string, config map[string]interface{}) {
+	t.Helper()
+	t.Cleanup(fs.Reset())
+	if config != nil {
+		if err := fs.SetConfig(config); err != nil {
+			t.Fatalf("flags: set config: %v", err)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("flags: parse %v: %v", args, err)
+	}
+}
+
+// Parse is ParseIn on the default set, so that the config of packages
+// registering their flags at init can be set in tests.
+func Parse(t testing.TB, args []string, config map[string]interface{}) {
+	t.Helper()
+	ParseIn(t, flags.CommandLine(), args, config)
+}
@@ -0,0 +1,44 @@
+package flagstest
+
+import (
+	"testing"
+
+	"github.com/superwhys/goutils/flags"
+)
+
+type testConf struct {
+	Addr string
+	Port int
+}
+
+func (c *testConf) SetDefault() {
+	c.Port = 80
+}
+
+func TestParseIn(t *testing.T) {
+	fs := flags.NewFlagSet("test")
+	name := fs.String("name", "default", "name")
+	conf := flags.StructOfIn(fs, "conf", testConf{Addr: "localhost"}, "conf")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("override", func(t *testing.T) {
+		ParseIn(t, fs, []string{"--name", "test"}, map[string]interface{}{
+			"conf": map[string]interface{}{"addr": "remote", "port": 6379},
+		})
+		if name() != "test" {
+			t.Errorf("name: want test, got %v", name())
+		}
+		if c := conf(); c.Addr != "remote" || c.Port != 6379 {
+			t.Errorf("conf: unexpected %+v", c)
+		}
+	})
+
+	if name() != "default" {
+		t.Errorf("name: want restored default, got %v", name())
+	}
+	if c := conf(); c.Addr != "localhost" || c.Port != 80 {
+		t.Errorf("conf: want restored default, got %+v", c)
+	}
+}
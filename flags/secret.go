@@ -0,0 +1,162 @@
+package flags
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/superwhys/goutils/lg"
+)
+
+const (
+	Redacted = lg.Redacted
+
+	secretFilePrefix = "file://"
+	secretEnvPrefix  = "env://"
+)
+
+var (
+	secretType = reflect.TypeOf(Secret{})
+)
+
+type secretState struct {
+	mu      sync.Mutex
+	value   string
+	modTime time.Time
+	size    int64
+	loaded  bool
+}
+
+// Secret is a config value which is redacted whenever it is printed,
+// e.g. in help, --dump-config, lg.Jsonify or fmt.
+// The configured value can refer to the place the secret is stored:
+//
+//	file:///run/secrets/redis-password  read from the file, and read again when the file changes
+//	env://REDIS_PASSWORD                read from the env var
+//	anything else                       used as it is
+type Secret struct {
+	ref   string
+	state *secretState
+}
+
+func NewSecret(ref string) Secret {
+	return Secret{ref: ref, state: &secretState{}}
+}
+
+// Value returns the resolved secret value.
+func (s Secret) Value() string {
+	switch {
+	case strings.HasPrefix(s.ref, secretEnvPrefix):
+		return os.Getenv(strings.TrimPrefix(s.ref, secretEnvPrefix))
+	case strings.HasPrefix(s.ref, secretFilePrefix):
+		return s.readFile(strings.TrimPrefix(s.ref, secretFilePrefix))
+	default:
+		return s.ref
+	}
+}
+
+func (s Secret) readFile(path string) string {
+	state := s.state
+	if state == nil {
+		state = &secretState{}
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		lg.Errorf("Stat secret file %v error: %v", path, err)
+		return state.value
+	}
+	if state.loaded && info.ModTime().Equal(state.modTime) && info.Size() == state.size {
+		return state.value
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		lg.Errorf("Read secret file %v error: %v", path, err)
+		return state.value
+	}
+	state.value = strings.TrimRight(string(data), "\r\n")
+	state.modTime = info.ModTime()
+	state.size = info.Size()
+	state.loaded = true
+	return state.value
+}
+
+// IsEmpty reports whether no secret is configured.
+func (s Secret) IsEmpty() bool {
+	return s.ref == ""
+}
+
+func (s Secret) String() string {
+	return redact(s.ref)
+}
+
+func (s Secret) GoString() string {
+	return `flags.Secret("` + redact(s.ref) + `")`
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redact(s.ref))
+}
+
+func (s *Secret) UnmarshalText(text []byte) error {
+	*s = NewSecret(string(text))
+	return nil
+}
+
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// secretValue is the pflag.Value of a Secret. Unlike Secret, its String
+// returns the raw value, so that viper can read the flag.
+type secretValue struct {
+	secret *Secret
+}
+
+func (sv *secretValue) String() string {
+	if sv == nil || sv.secret == nil {
+		return ""
+	}
+	return sv.secret.ref
+}
+
+func (sv *secretValue) Set(s string) error {
+	*sv.secret = NewSecret(s)
+	return nil
+}
+
+func (sv *secretValue) Type() string {
+	return "secret"
+}
+
+// markSecret hides the default value of the flag in help, and the value of the key in dumps.
+func (s *flagScope) markSecret(key string, resolve bool) {
+	s.set.secretKeys[strings.ToLower(key)] = resolve
+	if f := s.fs.Lookup(key); f != nil {
+		f.DefValue = redact(f.DefValue)
+	}
+}
+
+func (s *FlagSet) isSecret(key string) bool {
+	_, ok := s.secretKeys[strings.ToLower(key)]
+	return ok
+}
+
+// resolveSecret resolves the file and env reference of the string values
+// of keys tagged with `secret:"true"`.
+func (s *FlagSet) resolveSecret(key string, val interface{}) interface{} {
+	str, ok := val.(string)
+	if !ok || !s.secretKeys[strings.ToLower(key)] {
+		return val
+	}
+	return NewSecret(str).Value()
+}
@@ -0,0 +1,417 @@
+package flags
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/pflag"
+	"github.com/superwhys/goutils/lg"
+)
+
+var (
+	pflagValueType      = reflect.TypeOf((*pflag.Value)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// textValue adapts an encoding.TextUnmarshaler to pflag.Value.
+type textValue struct {
+	ptr reflect.Value
+}
+
+func (t *textValue) String() string {
+	if t == nil || !t.ptr.IsValid() || t.ptr.IsNil() {
+		return ""
+	}
+	if m, ok := t.ptr.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+	return fmt.Sprint(t.ptr.Elem().Interface())
+}
+
+func (t *textValue) Set(s string) error {
+	return t.ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+}
+
+func (t *textValue) Type() string {
+	return t.ptr.Elem().Type().String()
+}
+
+func (s *flagScope) setPFlag(key string, defaultValue reflect.Value) {
+	s.bind(func() {
+		s.set.v.BindPFlag(key, s.fs.Lookup(key))
+		s.set.bindEnv(key)
+		if !defaultValue.IsZero() {
+			s.set.leafDefaults[strings.ToLower(key)] = defaultValue.Interface()
+		}
+	})
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"flags", "flag", "json", "bson", "mapstructure"} {
+		if content := field.Tag.Get(tag); content != "" {
+			return strings.SplitN(content, ",", 2)[0]
+		}
+	}
+	return ""
+}
+
+func (s *flagScope) setPFlagRecursively(prefix string, i interface{}) error {
+	if i == nil {
+		return errors.New("not struct")
+	}
+	vf := reflect.ValueOf(i)
+	if vf.Kind() == reflect.Ptr {
+		if vf.IsNil() {
+			vf = reflect.New(vf.Type().Elem())
+		}
+		vf = vf.Elem()
+	}
+	if vf.Kind() != reflect.Struct {
+		return errors.New("not struct")
+	}
+	for i := 0; i < vf.NumField(); i++ {
+		field := vf.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := fieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		fv := vf.Field(i)
+		if field.Anonymous && name == "" {
+			// Embedded struct is flattened into its parent, the same as the `squash` of mapstructure.
+			if err := s.setPFlagRecursively(prefix, fv.Interface()); err != nil {
+				lg.Warn("Ignore embedded field", prefix, field.Name, err)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		name = prefix + "." + name
+
+		if err := s.setFieldPFlag(name, field.Tag.Get("desc"), fv); err != nil {
+			lg.Warn("Ignore flag key", name, err)
+			continue
+		}
+		if field.Tag.Get("secret") == "true" {
+			s.markSecret(name, fv.Kind() == reflect.String)
+		}
+	}
+
+	return nil
+}
+
+func (s *flagScope) setFieldPFlag(name, desc string, fv reflect.Value) error {
+	ft := fv.Type()
+
+	if ft == secretType {
+		secret := fv.Interface().(Secret)
+		s.fs.Var(&secretValue{secret: &secret}, name, desc)
+		s.setPFlag(name, fv)
+		s.markSecret(name, false)
+		return nil
+	}
+
+	// Custom types are checked before their kinds, as they are
+	// usually based on a string or a struct.
+	if reflect.PtrTo(ft).Implements(pflagValueType) || reflect.PtrTo(ft).Implements(textUnmarshalerType) {
+		ptr := reflect.New(ft)
+		ptr.Elem().Set(fv)
+
+		var value pflag.Value
+		if pv, ok := ptr.Interface().(pflag.Value); ok {
+			value = pv
+		} else {
+			value = &textValue{ptr: ptr}
+		}
+		s.fs.Var(value, name, desc)
+		s.setPFlag(name, fv)
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.Bool:
+		s.fs.Bool(name, fv.Bool(), desc)
+	case reflect.Int:
+		s.fs.Int(name, int(fv.Int()), desc)
+	case reflect.Int8:
+		s.fs.Int8(name, int8(fv.Int()), desc)
+	case reflect.Int16:
+		s.fs.Int16(name, int16(fv.Int()), desc)
+	case reflect.Int32:
+		s.fs.Int32(name, int32(fv.Int()), desc)
+	case reflect.Int64:
+		if ft == durationType {
+			s.fs.Duration(name, time.Duration(fv.Int()), desc)
+		} else {
+			s.fs.Int64(name, fv.Int(), desc)
+		}
+	case reflect.Uint:
+		s.fs.Uint(name, uint(fv.Uint()), desc)
+	case reflect.Uint8:
+		s.fs.Uint8(name, uint8(fv.Uint()), desc)
+	case reflect.Uint16:
+		s.fs.Uint16(name, uint16(fv.Uint()), desc)
+	case reflect.Uint32:
+		s.fs.Uint32(name, uint32(fv.Uint()), desc)
+	case reflect.Uint64:
+		s.fs.Uint64(name, fv.Uint(), desc)
+	case reflect.Float32:
+		s.fs.Float32(name, float32(fv.Float()), desc)
+	case reflect.Float64:
+		s.fs.Float64(name, fv.Float(), desc)
+	case reflect.String:
+		s.fs.String(name, fv.String(), desc)
+	case reflect.Slice:
+		if err := s.setSlicePFlag(name, desc, fv); err != nil {
+			return err
+		}
+	case reflect.Map:
+		if err := s.setMapPFlag(name, desc, fv); err != nil {
+			return err
+		}
+	case reflect.Struct:
+		return s.setPFlagRecursively(name, fv.Interface())
+	case reflect.Ptr:
+		if ft.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("Unsupport type %s", ft.String())
+		}
+		return s.setPFlagRecursively(name, fv.Interface())
+	default:
+		return fmt.Errorf("Unsupport kind %s", fv.Kind())
+	}
+
+	s.setPFlag(name, fv)
+	return nil
+}
+
+func (s *flagScope) setSlicePFlag(name, desc string, fv reflect.Value) error {
+	ft := fv.Type()
+	if ft.Elem() == durationType {
+		s.fs.DurationSlice(name, fv.Convert(reflect.TypeOf([]time.Duration(nil))).Interface().([]time.Duration), desc)
+		return nil
+	}
+
+	switch ft.Elem().Kind() {
+	case reflect.Int:
+		s.fs.IntSlice(name, fv.Convert(reflect.TypeOf([]int(nil))).Interface().([]int), desc)
+	case reflect.Int32:
+		s.fs.Int32Slice(name, fv.Convert(reflect.TypeOf([]int32(nil))).Interface().([]int32), desc)
+	case reflect.Int64:
+		s.fs.Int64Slice(name, fv.Convert(reflect.TypeOf([]int64(nil))).Interface().([]int64), desc)
+	case reflect.Uint:
+		s.fs.UintSlice(name, fv.Convert(reflect.TypeOf([]uint(nil))).Interface().([]uint), desc)
+	case reflect.String:
+		s.fs.StringSlice(name, fv.Convert(reflect.TypeOf([]string(nil))).Interface().([]string), desc)
+	case reflect.Float32:
+		s.fs.Float32Slice(name, fv.Convert(reflect.TypeOf([]float32(nil))).Interface().([]float32), desc)
+	case reflect.Float64:
+		s.fs.Float64Slice(name, fv.Convert(reflect.TypeOf([]float64(nil))).Interface().([]float64), desc)
+	case reflect.Bool:
+		s.fs.BoolSlice(name, fv.Convert(reflect.TypeOf([]bool(nil))).Interface().([]bool), desc)
+	default:
+		return fmt.Errorf("Unsupport type %s", ft.String())
+	}
+	return nil
+}
+
+func (s *flagScope) setMapPFlag(name, desc string, fv reflect.Value) error {
+	ft := fv.Type()
+	if ft.Key().Kind() != reflect.String {
+		return fmt.Errorf("Unsupport type %s", ft.String())
+	}
+
+	switch ft.Elem().Kind() {
+	case reflect.String:
+		s.fs.StringToString(name, fv.Convert(reflect.TypeOf(map[string]string(nil))).Interface().(map[string]string), desc)
+	case reflect.Int:
+		s.fs.StringToInt(name, fv.Convert(reflect.TypeOf(map[string]int(nil))).Interface().(map[string]int), desc)
+	case reflect.Int64:
+		s.fs.StringToInt64(name, fv.Convert(reflect.TypeOf(map[string]int64(nil))).Interface().(map[string]int64), desc)
+	default:
+		return fmt.Errorf("Unsupport type %s", ft.String())
+	}
+	return nil
+}
+
+// unmarshalKey decodes the struct config stored under key into out.
+// Every nested key is looked up on its own, so that values coming from
+// flags, env and config file are merged instead of shadowing each other.
+func (s *FlagSet) unmarshalKey(key string, out interface{}) error {
+	settings := map[string]interface{}{}
+	prefix := strings.ToLower(key) + "."
+	s.mu.RLock()
+	for _, k := range s.v.AllKeys() {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		val := s.v.Get(k)
+		if val == nil {
+			val = s.leafDefaults[k]
+		}
+		if val == nil {
+			continue
+		}
+		val = s.resolveSecret(k, val)
+
+		m := settings
+		path := strings.Split(strings.TrimPrefix(k, prefix), ".")
+		for _, p := range path[:len(path)-1] {
+			sub, ok := m[p].(map[string]interface{})
+			if !ok {
+				sub = map[string]interface{}{}
+				m[p] = sub
+			}
+			m = sub
+		}
+		m[path[len(path)-1]] = val
+	}
+	s.mu.RUnlock()
+
+	if len(settings) == 0 {
+		return nil
+	}
+
+	return decode(settings, out)
+}
+
+// decodeTagName is a tag no field has, so that mapstructure matches the
+// fields by their Go names, which fieldsHookFunc keys the settings by.
+const decodeTagName = "flagsdecode"
+
+func decode(input, out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           out,
+		WeaklyTypedInput: true,
+		TagName:          decodeTagName,
+		DecodeHook:       decodeHookFunc(),
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
+
+// fieldsHookFunc keys the settings of a struct by the Go names of its fields,
+// looking them up by the names of their flags: the embedded structs without
+// a name are flattened into their parent, as in setPFlagRecursively.
+func fieldsHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		settings, ok := data.(map[string]interface{})
+		if !ok || t.Kind() != reflect.Struct {
+			return data, nil
+		}
+
+		byName := make(map[string]interface{}, len(settings))
+		for k, v := range settings {
+			byName[strings.ToLower(k)] = v
+		}
+		fields := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := fieldName(field)
+			if name == "-" {
+				continue
+			}
+			if field.Anonymous && name == "" {
+				// The fields of the embedded struct are looked up in the settings of its parent.
+				if ft := field.Type; ft.Kind() == reflect.Struct || (ft.Kind() == reflect.Ptr && ft.Elem().Kind() == reflect.Struct) {
+					fields[field.Name] = settings
+				}
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if v, ok := byName[strings.ToLower(name)]; ok {
+				fields[field.Name] = v
+			}
+		}
+		return fields, nil
+	}
+}
+
+// trimBrackets removes the brackets pflag wraps around the
+// string form of slice and map values.
+func trimBrackets(s string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "["), "]")
+}
+
+func stringToSliceHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || t.Kind() != reflect.Slice {
+			return data, nil
+		}
+		s := trimBrackets(data.(string))
+		if s == "" {
+			return []string{}, nil
+		}
+		return strings.Split(s, ","), nil
+	}
+}
+
+func parseStringMap(s string) (map[string]string, error) {
+	s = trimBrackets(s)
+	m := map[string]string{}
+	if s == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%s must be formatted as key=value", pair)
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return m, nil
+}
+
+func stringToMapHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || t.Kind() != reflect.Map {
+			return data, nil
+		}
+		return parseStringMap(data.(string))
+	}
+}
+
+func pflagValueHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String || !reflect.PtrTo(t).Implements(pflagValueType) {
+			return data, nil
+		}
+		ptr := reflect.New(t)
+		if err := ptr.Interface().(pflag.Value).Set(data.(string)); err != nil {
+			return nil, err
+		}
+		return ptr.Elem().Interface(), nil
+	}
+}
+
+func decodeHookFunc() mapstructure.DecodeHookFunc {
+	// We use lazy function init to decouple cycle dependency.
+	return mapstructure.ComposeDecodeHookFunc(
+		fieldsHookFunc(),
+		pflagValueHookFunc(),
+		mapstructure.TextUnmarshallerHookFunc(),
+		mapstructure.StringToTimeDurationHookFunc(),
+		stringToSliceHookFunc(),
+		stringToMapHookFunc(),
+	)
+}
@@ -0,0 +1,188 @@
+package flags
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// modeValue is a pflag.Value.
+type modeValue string
+
+func (m *modeValue) String() string { return string(*m) }
+
+func (m *modeValue) Set(s string) error {
+	*m = modeValue(strings.ToUpper(s))
+	return nil
+}
+
+func (m *modeValue) Type() string { return "mode" }
+
+type StructBase struct {
+	Name string
+}
+
+type structInner struct {
+	Level int
+}
+
+type structConf struct {
+	StructBase
+	Inner *structInner
+
+	Labels map[string]string
+	Limits map[string]int
+	Sizes  map[string]int64
+
+	I   int
+	I8  int8
+	I16 int16
+	I32 int32
+	I64 int64
+	U   uint
+	U8  uint8
+	U16 uint16
+	U32 uint32
+	U64 uint64
+	F32 float32
+	F64 float64
+
+	IP   net.IP
+	Mode modeValue
+}
+
+var structWant = structConf{
+	StructBase: StructBase{Name: "svc"},
+	Inner:      &structInner{Level: 3},
+	Labels:     map[string]string{"env": "prod"},
+	Limits:     map[string]int{"cpu": 2},
+	Sizes:      map[string]int64{"disk": 1 << 40},
+	I:          -1, I8: -8, I16: -16, I32: -32, I64: -1 << 40,
+	U: 1, U8: 8, U16: 16, U32: 32, U64: 1 << 40,
+	F32: 1.5, F64: 2.25,
+	IP:   net.ParseIP("10.0.0.1"),
+	Mode: "FAST",
+}
+
+func TestStructFromFlags(t *testing.T) {
+	fs := NewFlagSet("test")
+	conf := StructOfIn(fs, "conf", structConf{}, "conf")
+	err := fs.Parse([]string{
+		"--conf.Name=svc", "--conf.Inner.Level=3",
+		"--conf.Labels=env=prod", "--conf.Limits=cpu=2", "--conf.Sizes=disk=1099511627776",
+		"--conf.I=-1", "--conf.I8=-8", "--conf.I16=-16", "--conf.I32=-32", "--conf.I64=-1099511627776",
+		"--conf.U=1", "--conf.U8=8", "--conf.U16=16", "--conf.U32=32", "--conf.U64=1099511627776",
+		"--conf.F32=1.5", "--conf.F64=2.25",
+		"--conf.IP=10.0.0.1", "--conf.Mode=fast",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := conf(); !reflect.DeepEqual(got, structWant) {
+		t.Errorf("conf from flags:\n%+v\nwant:\n%+v", got, structWant)
+	}
+}
+
+func TestStructFromFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := `conf:
+  name: svc
+  inner:
+    level: 3
+  labels:
+    env: prod
+  limits:
+    cpu: 2
+  sizes:
+    disk: 1099511627776
+  i: -1
+  i8: -8
+  i16: -16
+  i32: -32
+  i64: -1099511627776
+  u: 1
+  u8: 8
+  u16: 16
+  u32: 32
+  u64: 1099511627776
+  f32: 1.5
+  f64: 2.25
+  ip: 10.0.0.1
+  mode: FAST
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := NewFlagSet("test")
+	conf := StructOfIn(fs, "conf", structConf{}, "conf")
+	if err := fs.ReadConfigFile(file, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := conf(); !reflect.DeepEqual(got, structWant) {
+		t.Errorf("conf from file:\n%+v\nwant:\n%+v", got, structWant)
+	}
+}
+
+func TestStructNilPointer(t *testing.T) {
+	fs := NewFlagSet("test")
+	conf := StructOfIn(fs, "conf", structConf{}, "conf")
+	if fs.fs.Lookup("conf.Inner.Level") == nil {
+		t.Fatal("no flag for the field of a nil struct pointer")
+	}
+	if fs.fs.Lookup("conf.Name") == nil {
+		t.Fatal("embedded struct not flattened")
+	}
+	if err := fs.Parse([]string{"--conf.Inner.Level=5"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := conf(); got.Inner == nil || got.Inner.Level != 5 {
+		t.Errorf("inner: want level 5, got %+v", got.Inner)
+	}
+}
+
+type structTagged struct {
+	StructBase `mapstructure:"base"`
+	Port       int `json:"port_number"`
+}
+
+func TestStructTaggedEmbedded(t *testing.T) {
+	want := structTagged{StructBase: StructBase{Name: "svc"}, Port: 8080}
+
+	fs := NewFlagSet("test")
+	conf := StructOfIn(fs, "conf", structTagged{}, "conf")
+	for _, name := range []string{"conf.base.Name", "conf.port_number"} {
+		if fs.fs.Lookup(name) == nil {
+			t.Fatalf("no flag %s", name)
+		}
+	}
+	if err := fs.Parse([]string{"--conf.base.Name=svc", "--conf.port_number=8080"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := conf(); got != want {
+		t.Errorf("conf from flags: %+v, want %+v", got, want)
+	}
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "conf:\n  base:\n    name: svc\n  port_number: 8080\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs = NewFlagSet("test")
+	conf = StructOfIn(fs, "conf", structTagged{}, "conf")
+	if err := fs.ReadConfigFile(file, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := conf(); got != want {
+		t.Errorf("conf from file: %+v, want %+v", got, want)
+	}
+}
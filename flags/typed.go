@@ -0,0 +1,166 @@
+package flags
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/superwhys/goutils/lg"
+)
+
+type snapshot[T any] struct {
+	version uint64
+	value   T
+}
+
+// typedValue caches the decoded value of a key until the config is reloaded.
+type typedValue[T any] struct {
+	set  *FlagSet
+	key  string
+	load func() (T, error)
+
+	mu   sync.Mutex
+	snap atomic.Pointer[snapshot[T]]
+}
+
+func newTypedValue[T any](scope *flagScope, key string, load func() (T, error)) *typedValue[T] {
+	tv := &typedValue[T]{set: scope.set, key: key, load: load}
+	scope.checks = append(scope.checks, func() error {
+		_, err := tv.reload(tv.set.version.Load())
+		return err
+	})
+	return tv
+}
+
+func (tv *typedValue[T]) reload(version uint64) (T, error) {
+	tv.mu.Lock()
+	defer tv.mu.Unlock()
+
+	if s := tv.snap.Load(); s != nil && s.version == version {
+		return s.value, nil
+	}
+
+	value, err := tv.load()
+	if err != nil {
+		err = fmt.Errorf("decode config --%s: %w", tv.key, err)
+		if s := tv.snap.Load(); s != nil {
+			// Keep serving the last valid value and don't retry until the next reload.
+			tv.snap.Store(&snapshot[T]{version: version, value: s.value})
+			return s.value, err
+		}
+		return value, err
+	}
+	tv.snap.Store(&snapshot[T]{version: version, value: value})
+	return value, nil
+}
+
+func (tv *typedValue[T]) get() T {
+	version := tv.set.version.Load()
+	if s := tv.snap.Load(); s != nil && s.version == version {
+		return s.value
+	}
+
+	value, err := tv.reload(version)
+	if err != nil {
+		lg.Error(err)
+	}
+	return value
+}
+
+// decodeTyped decodes into a fresh T. If T is a pointer, the value it
+// points to is allocated and decoded into.
+func decodeTyped[T any](decodeFn func(out interface{}) error) (T, error) {
+	var zero T
+	rt := reflect.TypeOf((*T)(nil)).Elem()
+	if rt.Kind() == reflect.Ptr {
+		ptr := reflect.New(rt.Elem())
+		if err := decodeFn(ptr.Interface()); err != nil {
+			return zero, err
+		}
+		return ptr.Interface().(T), nil
+	}
+
+	ptr := new(T)
+	if err := decodeFn(ptr); err != nil {
+		return zero, err
+	}
+	return *ptr, nil
+}
+
+// StructOf is the typed version of Struct. The returned function decodes
+// the config once, and caches it until the config is reloaded, so it is
+// cheap to call it anywhere.
+// Decode or validation error fails the Parse.
+// example:
+//
+//	redisConf := StructOf("redisConf", RedisConf{}, "redis config")
+//	conf := redisConf()
+//
+// If T is a pointer, the same pointer is returned until the config is reloaded,
+// so callers should not modify the value it points to.
+func StructOf[T any](key string, defaultValue T, usage string) func() T {
+	return structOf(defaultSet.flagScope, key, defaultValue, usage)
+}
+
+// StructOfIn is StructOf for the flags of a FlagSet or a Command.
+func StructOfIn[T any](r Registrar, key string, defaultValue T, usage string) func() T {
+	return structOf(r.scope(), key, defaultValue, usage)
+}
+
+func structOf[T any](scope *flagScope, key string, defaultValue T, usage string) func() T {
+	load := scope.Struct(key, defaultValue, usage)
+	tv := newTypedValue(scope, key, func() (T, error) {
+		return decodeTyped[T](load)
+	})
+	return tv.get
+}
+
+// Value registers a key of any type supported by Struct fields, e.g.
+// uint16, map[string]string, net.IP or a struct. Like StructOf, the decoded
+// value is cached until the config is reloaded.
+func Value[T any](key string, defaultValue T, usage string) func() T {
+	return value(defaultSet.flagScope, key, defaultValue, usage)
+}
+
+// ValueIn is Value for the flags of a FlagSet or a Command.
+func ValueIn[T any](r Registrar, key string, defaultValue T, usage string) func() T {
+	return value(r.scope(), key, defaultValue, usage)
+}
+
+func value[T any](scope *flagScope, key string, defaultValue T, usage string) func() T {
+	rv := reflect.ValueOf(defaultValue)
+	if !rv.IsValid() {
+		lg.Fatal(fmt.Sprintf("Value of interface type is not supported, Key: --%s", key))
+	}
+	if rv.Kind() == reflect.Struct || (rv.Kind() == reflect.Ptr && rv.Type().Elem().Kind() == reflect.Struct) {
+		return structOf(scope, key, defaultValue, usage)
+	}
+
+	scope.register(func() {
+		if err := scope.setFieldPFlag(key, usage, rv); err != nil {
+			lg.Fatal(fmt.Sprintf("Register flag err, Key: --%s: %v", key, err))
+		}
+		scope.set.allKeys = append(scope.set.allKeys, key)
+		scope.bind(func() {
+			scope.set.v.SetDefault(key, defaultValue)
+		})
+	})
+
+	tv := newTypedValue(scope, key, func() (T, error) {
+		return decodeTyped[T](func(out interface{}) error {
+			val := scope.set.get(key)
+			if val == nil {
+				return nil
+			}
+			if err := decode(val, out); err != nil {
+				return err
+			}
+			if vd, ok := out.(HasValidator); ok {
+				return vd.Validate()
+			}
+			return nil
+		})
+	})
+	return tv.get
+}
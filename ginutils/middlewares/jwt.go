@@ -25,7 +25,7 @@ func GenerateJWTAuth(signKey string, claims jwt.Claims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenStr, err := token.SignedString([]byte(signKey))
 	if err != nil {
-		lg.Errorf("jwt sign with key: %v error: %v", signKey, err)
+		lg.Errorf("jwt sign error: %v", err)
 		return "", errors.Wrap(err, "signedToken")
 	}
 
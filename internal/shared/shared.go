@@ -1,11 +1,24 @@
 package shared
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type LogConfig struct {
-	FileName  string `desc:"output filename (default runlog.log)"`
-	MaxSize   int    `desc:"file max szie (default 3)"`
-	MaxBackup int    `desc:"max backup count (default 3)"`
-	MaxAge    int    `desc:"max backup age (default 30)"`
-	Compress  bool   `desc:"whether to use compress (default false)"`
+	FileName         string        `desc:"output filename (default runlog.log)"`
+	ErrorFileName    string        `desc:"output filename of the error lines, empty writes them to the output filename (default empty)"`
+	MaxSize          int           `desc:"file max szie (default 3)"`
+	MaxBackup        int           `desc:"max backup count (default 3)"`
+	MaxAge           int           `desc:"max backup age (default 30)"`
+	Compress         bool          `desc:"whether to use compress (default false)"`
+	Format           string        `desc:"log format: text, logfmt or json (default text)"`
+	AsyncSize        int           `desc:"lines buffered to write the log asynchronously, 0 writes synchronously (default 0)"`
+	AsyncPolicy      string        `desc:"what to do when the async buffer is full: block, drop-debug-first or drop-oldest (default block)"`
+	SampleFirst      int           `desc:"log the first N lines of every message per interval, 0 disables the sampling (default 0)"`
+	SampleThereafter int           `desc:"log 1 in M lines of a message after the first ones (default 100)"`
+	SampleInterval   time.Duration `desc:"interval of the log sampling (default 1m)"`
 }
 
 var (
@@ -34,6 +47,29 @@ func (l *LogConfig) SetDefault() {
 	l.MaxBackup = 3
 	l.MaxAge = 30
 	l.Compress = false
+	l.Format = "text"
+	l.AsyncPolicy = "block"
+	l.SampleThereafter = 100
+	l.SampleInterval = time.Minute
+}
+
+func (l *LogConfig) Validate() error {
+	switch strings.ToLower(l.Format) {
+	case "", "text", "logfmt", "json":
+	default:
+		return fmt.Errorf("unknown log format: %s", l.Format)
+	}
+
+	switch strings.ToLower(l.AsyncPolicy) {
+	case "", "block", "drop-debug-first", "drop-oldest":
+	default:
+		return fmt.Errorf("unknown async log policy: %s", l.AsyncPolicy)
+	}
+
+	if l.SampleFirst > 0 && l.SampleInterval <= 0 {
+		return fmt.Errorf("log sample interval must be positive: %v", l.SampleInterval)
+	}
+	return nil
 }
 
 func GetLogConfig() *LogConfig {
@@ -0,0 +1,284 @@
+package lg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncPolicy is what the async writer does with a new line when its buffer is full.
+type AsyncPolicy int
+
+const (
+	// PolicyBlock waits for the buffer to have room, nothing is dropped.
+	PolicyBlock AsyncPolicy = iota
+	// PolicyDropDebugFirst drops the oldest buffered trace or debug line, or the
+	// new line if it is one. A full buffer of info and above lines blocks.
+	PolicyDropDebugFirst
+	// PolicyDropOldest drops the oldest buffered line.
+	PolicyDropOldest
+)
+
+func (p AsyncPolicy) String() string {
+	switch p {
+	case PolicyBlock:
+		return "block"
+	case PolicyDropDebugFirst:
+		return "drop-debug-first"
+	case PolicyDropOldest:
+		return "drop-oldest"
+	default:
+		return fmt.Sprintf("policy(%d)", int(p))
+	}
+}
+
+func ParseAsyncPolicy(s string) (AsyncPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "block":
+		return PolicyBlock, nil
+	case "drop-debug-first":
+		return PolicyDropDebugFirst, nil
+	case "drop-oldest":
+		return PolicyDropOldest, nil
+	default:
+		return 0, fmt.Errorf("unknown async log policy: %s", s)
+	}
+}
+
+// AsyncStats are the counters of the async writer.
+type AsyncStats struct {
+	// Pending is the count of the lines waiting to be written.
+	Pending int
+	// Dropped is the count of the lines dropped by the policy, by level.
+	Dropped map[Level]uint64
+}
+
+type asyncLine struct {
+	w     io.Writer
+	level Level
+	data  []byte
+}
+
+// asyncWriter is a bounded ring buffer of formatted lines written by a
+// background goroutine.
+type asyncWriter struct {
+	mu sync.Mutex
+	// ready is signaled when a line is queued or the writer is closed.
+	ready *sync.Cond
+	// room is signaled when lines are taken out of the buffer.
+	room *sync.Cond
+	// idle is broadcast when the buffer is empty and nothing is being written.
+	idle *sync.Cond
+
+	lines   []asyncLine
+	head    int
+	size    int
+	writing bool
+	closed  bool
+	done    chan struct{}
+
+	policy  AsyncPolicy
+	dropped [OffLevel]atomic.Uint64
+}
+
+func newAsyncWriter(size int, policy AsyncPolicy) *asyncWriter {
+	a := &asyncWriter{
+		lines:  make([]asyncLine, size),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	a.ready = sync.NewCond(&a.mu)
+	a.room = sync.NewCond(&a.mu)
+	a.idle = sync.NewCond(&a.mu)
+	go a.run()
+	return a
+}
+
+func (a *asyncWriter) at(i int) *asyncLine {
+	return &a.lines[(a.head+i)%len(a.lines)]
+}
+
+func (a *asyncWriter) push(line asyncLine) {
+	*a.at(a.size) = line
+	a.size++
+}
+
+func (a *asyncWriter) drop(level Level) {
+	if level >= 0 && level < OffLevel {
+		a.dropped[level].Add(1)
+	}
+}
+
+// remove takes the i-th buffered line out, shifting the newer lines.
+func (a *asyncWriter) remove(i int) {
+	for ; i < a.size-1; i++ {
+		*a.at(i) = *a.at(i + 1)
+	}
+	*a.at(a.size - 1) = asyncLine{}
+	a.size--
+}
+
+// write queues a copy of data to be written to w.
+func (a *asyncWriter) write(w io.Writer, level Level, data []byte) {
+	line := asyncLine{w: w, level: level, data: append([]byte(nil), data...)}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for a.size == len(a.lines) && !a.closed {
+		switch a.policy {
+		case PolicyDropOldest:
+			a.drop(a.at(0).level)
+			a.remove(0)
+			continue
+		case PolicyDropDebugFirst:
+			if i := a.oldestDebug(); i >= 0 {
+				a.drop(a.at(i).level)
+				a.remove(i)
+				continue
+			}
+			if level <= DebugLevel {
+				a.drop(level)
+				return
+			}
+		}
+		a.room.Wait()
+	}
+	if a.closed {
+		// Closed meanwhile, the line is written synchronously.
+		w.Write(line.data)
+		return
+	}
+	a.push(line)
+	a.ready.Signal()
+}
+
+// oldestDebug returns the index of the oldest buffered trace or debug line, or -1.
+func (a *asyncWriter) oldestDebug() int {
+	for i := 0; i < a.size; i++ {
+		if a.at(i).level <= DebugLevel {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *asyncWriter) run() {
+	defer close(a.done)
+
+	var batch []asyncLine
+	for {
+		a.mu.Lock()
+		for a.size == 0 && !a.closed {
+			a.writing = false
+			a.idle.Broadcast()
+			a.ready.Wait()
+		}
+		if a.size == 0 && a.closed {
+			a.writing = false
+			a.idle.Broadcast()
+			a.mu.Unlock()
+			return
+		}
+		batch = batch[:0]
+		for a.size > 0 {
+			batch = append(batch, *a.at(0))
+			*a.at(0) = asyncLine{}
+			a.head = (a.head + 1) % len(a.lines)
+			a.size--
+		}
+		a.writing = true
+		a.room.Broadcast()
+		a.mu.Unlock()
+
+		for _, line := range batch {
+			line.w.Write(line.data)
+		}
+	}
+}
+
+// flush waits until the buffered lines are written.
+func (a *asyncWriter) flush() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for a.size > 0 || a.writing {
+		a.idle.Wait()
+	}
+}
+
+// close writes the buffered lines and stops the background goroutine.
+func (a *asyncWriter) close() {
+	a.mu.Lock()
+	a.closed = true
+	a.ready.Broadcast()
+	a.room.Broadcast()
+	a.mu.Unlock()
+	<-a.done
+}
+
+func (a *asyncWriter) stats() AsyncStats {
+	a.mu.Lock()
+	pending := a.size
+	a.mu.Unlock()
+
+	stats := AsyncStats{Pending: pending, Dropped: map[Level]uint64{}}
+	for level := range a.dropped {
+		if n := a.dropped[level].Load(); n > 0 {
+			stats.Dropped[Level(level)] = n
+		}
+	}
+	return stats
+}
+
+// WithAsync makes the logger write asynchronously, see SetAsync.
+func WithAsync(size int, policy AsyncPolicy) Option {
+	return func(l *Logger) {
+		l.SetAsync(size, policy)
+	}
+}
+
+// SetAsync makes the logger queue the formatted lines in a buffer of size
+// lines, written to the outputs by a background goroutine, so that a slow
+// output does not stall the callers. The policy applies when the buffer is
+// full. A size of 0 writes synchronously again, after the buffered lines.
+func (l *Logger) SetAsync(size int, policy AsyncPolicy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.async != nil {
+		l.async.close()
+		l.async = nil
+	}
+	if size > 0 {
+		l.async = newAsyncWriter(size, policy)
+	}
+}
+
+// Flush waits until the lines buffered by the async writer are written,
+// then flushes the sinks buffering lines.
+func (l *Logger) Flush() {
+	l.mu.Lock()
+	a := l.async
+	l.mu.Unlock()
+
+	if a != nil {
+		a.flush()
+	}
+	l.flushSinks()
+}
+
+// AsyncStats returns the counters of the async writer, which are zero if
+// the logger writes synchronously.
+func (l *Logger) AsyncStats() AsyncStats {
+	l.mu.Lock()
+	a := l.async
+	l.mu.Unlock()
+
+	if a == nil {
+		return AsyncStats{Dropped: map[Level]uint64{}}
+	}
+	return a.stats()
+}
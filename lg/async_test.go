@@ -0,0 +1,121 @@
+package lg
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateWriter blocks every write until the gate is opened.
+type gateWriter struct {
+	gate chan struct{}
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gateWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy AsyncPolicy
+		// levels are written while the output is stalled by the first line.
+		levels  []Level
+		want    []string
+		dropped map[Level]uint64
+	}{
+		{
+			name:    "drop oldest",
+			policy:  PolicyDropOldest,
+			levels:  []Level{InfoLevel, WarnLevel, ErrorLevel, InfoLevel},
+			want:    []string{"first", "line2", "line3"},
+			dropped: map[Level]uint64{InfoLevel: 1, WarnLevel: 1},
+		},
+		{
+			name:    "drop debug first",
+			policy:  PolicyDropDebugFirst,
+			levels:  []Level{DebugLevel, InfoLevel, TraceLevel, DebugLevel},
+			want:    []string{"first", "line1", "line3"},
+			dropped: map[Level]uint64{DebugLevel: 1, TraceLevel: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &gateWriter{gate: make(chan struct{})}
+			l := New(WithAsync(2, tt.policy))
+			defer l.SetAsync(0, PolicyBlock)
+			l.SetLoggerOutput(w, w)
+
+			l.output(1, InfoLevel, "first", nil)
+			// Wait for the first line to be taken out of the buffer.
+			for l.AsyncStats().Pending != 0 {
+				time.Sleep(time.Millisecond)
+			}
+			for i, level := range tt.levels {
+				l.output(1, level, "line"+string(rune('0'+i)), nil)
+			}
+			if got := l.AsyncStats().Dropped; !equalCounts(got, tt.dropped) {
+				t.Errorf("dropped = %v, want %v", got, tt.dropped)
+			}
+
+			close(w.gate)
+			l.Flush()
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(w.String()), "\n") {
+				got = append(got, line[strings.LastIndex(line, " ")+1:])
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("lines = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAsyncBlock(t *testing.T) {
+	w := &gateWriter{gate: make(chan struct{})}
+	l := New(WithAsync(1, PolicyBlock))
+	l.SetLoggerOutput(w, w)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			l.output(1, DebugLevel, "line", nil)
+		}
+	}()
+	close(w.gate)
+	<-done
+	l.SetAsync(0, PolicyBlock)
+
+	if n := strings.Count(w.String(), "line"); n != 3 {
+		t.Errorf("written %d lines, want 3", n)
+	}
+	if dropped := l.AsyncStats().Dropped; len(dropped) != 0 {
+		t.Errorf("dropped = %v", dropped)
+	}
+}
+
+func equalCounts(a, b map[Level]uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
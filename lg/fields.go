@@ -0,0 +1,262 @@
+package lg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+type fieldKind uint8
+
+const (
+	anyField fieldKind = iota
+	stringField
+	intField
+	uintField
+	floatField
+	boolField
+	durationField
+	timeField
+	errorField
+)
+
+// Field is a typed key value pair of a log line, formatted without fmt.
+// A Field can be passed among the key value pairs of WithFields and the KV
+// functions, or to LogFields which allocates nothing when the level is disabled.
+type Field struct {
+	Key  string
+	kind fieldKind
+	num  int64
+	str  string
+	any  interface{}
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, kind: stringField, str: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, kind: intField, num: int64(value)}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, kind: intField, num: value}
+}
+
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, kind: uintField, num: int64(value)}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: floatField, num: int64(math.Float64bits(value))}
+}
+
+func Bool(key string, value bool) Field {
+	f := Field{Key: key, kind: boolField}
+	if value {
+		f.num = 1
+	}
+	return f
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: durationField, num: int64(value)}
+}
+
+// Time formats the value in RFC3339 with nanoseconds.
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, kind: timeField, any: value}
+}
+
+// Err is the field of an error with the key "error".
+func Err(err error) Field {
+	return Field{Key: "error", kind: errorField, any: err}
+}
+
+// Any formats the value like %v.
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, kind: anyField, any: value}
+}
+
+// Value returns the value as it is written in the log line.
+func (f Field) Value() string {
+	switch f.kind {
+	case stringField:
+		return f.str
+	case intField:
+		return strconv.FormatInt(f.num, 10)
+	case uintField:
+		return strconv.FormatUint(uint64(f.num), 10)
+	case floatField:
+		return strconv.FormatFloat(math.Float64frombits(uint64(f.num)), 'g', -1, 64)
+	case boolField:
+		return strconv.FormatBool(f.num == 1)
+	case durationField:
+		return time.Duration(f.num).String()
+	case timeField:
+		return f.any.(time.Time).Format(time.RFC3339Nano)
+	case errorField:
+		if f.any == nil {
+			return "<nil>"
+		}
+		return f.any.(error).Error()
+	default:
+		return formatValue(f.any)
+	}
+}
+
+// formatValue formats v like %v, the common types without fmt.
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case int:
+		return strconv.Itoa(x)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case int32:
+		return strconv.FormatInt(int64(x), 10)
+	case uint:
+		return strconv.FormatUint(uint64(x), 10)
+	case uint64:
+		return strconv.FormatUint(x, 10)
+	case uint32:
+		return strconv.FormatUint(uint64(x), 10)
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case time.Duration:
+		return x.String()
+	case error:
+		return x.Error()
+	case fmt.Stringer:
+		return x.String()
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// appendKV appends the key value pairs to the LogContext in their order.
+// kv holds Fields or alternating keys and values, a key without value
+// gets <Missing> like the args missing in With.
+func (lc *LogContext) appendKV(kv []interface{}) {
+	for i := 0; i < len(kv); i++ {
+		if f, ok := kv[i].(Field); ok {
+			lc.appendFields(f)
+			continue
+		}
+
+		key, ok := kv[i].(string)
+		if !ok {
+			key = formatValue(kv[i])
+		}
+		value := "<Missing>"
+		if i+1 < len(kv) {
+			i++
+			value = formatValue(kv[i])
+		}
+		lc.keys = append(lc.keys, key)
+		lc.values = append(lc.values, value)
+	}
+}
+
+func (lc *LogContext) appendFields(fields ...Field) {
+	for _, f := range fields {
+		lc.keys = append(lc.keys, f.Key)
+		lc.values = append(lc.values, f.Value())
+	}
+}
+
+// contextWith returns a copy of the LogContext of ctx with msg appended.
+func contextWith(ctx context.Context, msg string) *LogContext {
+	lc := cloneLogContext(ParseFromContext(ctx))
+	if lc == nil {
+		lc = &LogContext{}
+	}
+	if msg != "" {
+		lc.msg = append(lc.msg, msg)
+	}
+	return lc
+}
+
+// WithFields returns a copy of ctx whose LogContext has the key value pairs
+// appended, which are written by the c and KV functions after the ones
+// added before by With or WithFields.
+// example:
+//
+//	ctx = lg.WithFields(ctx, "user", name, lg.Int("retry", n))
+//	lg.Infoc(ctx, "login")
+func WithFields(ctx context.Context, kv ...interface{}) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+
+	lc := contextWith(ctx, "")
+	lc.appendKV(kv)
+	return context.WithValue(ctx, logContextKey, lc)
+}
+
+// logKV writes msg with the LogContext of ctx and the key value pairs.
+// It must be called by the exported functions for the caller depth.
+func logKV(ctx context.Context, level Level, msg string, kv []interface{}) {
+	lc := contextWith(ctx, msg)
+	lc.appendKV(kv)
+	logger.output(3, level, "", lc)
+}
+
+func TraceKV(ctx context.Context, msg string, kv ...interface{}) {
+	if allowed(ctx, TraceLevel, msg) {
+		logKV(ctx, TraceLevel, msg, kv)
+	}
+}
+
+func DebugKV(ctx context.Context, msg string, kv ...interface{}) {
+	if allowed(ctx, DebugLevel, msg) {
+		logKV(ctx, DebugLevel, msg, kv)
+	}
+}
+
+// InfoKV writes msg with the key value pairs, which are Fields or alternating
+// keys and values, after the ones of the LogContext of ctx.
+// example:
+//
+//	lg.InfoKV(ctx, "user login", "user", name, "ip", ip)
+func InfoKV(ctx context.Context, msg string, kv ...interface{}) {
+	if allowed(ctx, InfoLevel, msg) {
+		logKV(ctx, InfoLevel, msg, kv)
+	}
+}
+
+func WarnKV(ctx context.Context, msg string, kv ...interface{}) {
+	if allowed(ctx, WarnLevel, msg) {
+		logKV(ctx, WarnLevel, msg, kv)
+	}
+}
+
+func ErrorKV(ctx context.Context, msg string, kv ...interface{}) {
+	if allowed(ctx, ErrorLevel, msg) {
+		logKV(ctx, ErrorLevel, msg, kv)
+	}
+}
+
+// LogFields writes msg with the fields at the level, and exits like Fatal at
+// FatalLevel. Unlike the KV functions nothing is boxed in an interface, so
+// that it allocates nothing when the level is disabled.
+func LogFields(ctx context.Context, level Level, msg string, fields ...Field) {
+	if level < OffLevel && allowed(ctx, level, msg) {
+		lc := contextWith(ctx, msg)
+		lc.appendFields(fields...)
+		logger.output(2, level, "", lc)
+	}
+	if level == FatalLevel {
+		Flush()
+		os.Exit(1)
+	}
+}
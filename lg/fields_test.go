@@ -0,0 +1,65 @@
+package lg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithFields(t *testing.T) {
+	ctx := With(context.Background(), "request id=%v", 1)
+	ctx = WithFields(ctx, "user", "bob smith", Int("retry", 2), "path")
+	ctx = With(ctx, "handle code=%d", 200)
+
+	lc := ParseFromContext(ctx)
+	if want := []string{"request", "handle"}; !reflect.DeepEqual(lc.msg, want) {
+		t.Errorf("msg = %v, want %v", lc.msg, want)
+	}
+	if want := []string{"id", "user", "retry", "path", "code"}; !reflect.DeepEqual(lc.keys, want) {
+		t.Errorf("keys = %v, want %v", lc.keys, want)
+	}
+	if want := []string{"1", "bob smith", "2", "<Missing>", "200"}; !reflect.DeepEqual(lc.values, want) {
+		t.Errorf("values = %v, want %v", lc.values, want)
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		field Field
+		want  string
+	}{
+		{String("k", "a b"), "a b"},
+		{Int("k", -3), "-3"},
+		{Uint64("k", 1<<63), "9223372036854775808"},
+		{Float64("k", 1.5), "1.5"},
+		{Bool("k", true), "true"},
+		{Duration("k", 1500*time.Millisecond), "1.5s"},
+		{Time("k", tm), "2024-01-02T03:04:05Z"},
+		{Err(errors.New("boom")), "boom"},
+		{Err(nil), "<nil>"},
+		{Any("k", []int{1, 2}), "[1 2]"},
+	}
+	for _, tt := range tests {
+		if got := tt.field.Value(); got != tt.want {
+			t.Errorf("Value() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestKVDisabledAllocs(t *testing.T) {
+	restoreLevels(t)
+	SetLevel(WarnLevel)
+
+	ctx := WithFields(context.Background(), "user", "bob")
+	name, n := "alice", 1000
+	allocs := testing.AllocsPerRun(100, func() {
+		InfoKV(ctx, "login", "user", "bob", "retry", 3)
+		LogFields(ctx, DebugLevel, "login", String("user", name), Int("retry", n))
+	})
+	if allocs != 0 {
+		t.Errorf("disabled lines allocate %v times", allocs)
+	}
+}
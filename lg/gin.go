@@ -87,12 +87,16 @@ func LoggerMiddleware() gin.HandlerFunc {
 			logFunc = Errorf
 		}
 
+		statusClr, methodClr, resetClr := statusCodeColor(statusCode), methodColor(method), reset
+		if !logger.isColored() {
+			statusClr, methodClr, resetClr = "", "", ""
+		}
 		logFunc(
 			logMsg,
-			statusCodeColor(statusCode), statusCode, reset,
+			statusClr, statusCode, resetClr,
 			spendTime,
 			clientIp,
-			methodColor(method), method, reset,
+			methodClr, method, resetClr,
 			path,
 			c.Request.Proto,
 		)
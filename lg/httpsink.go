@@ -0,0 +1,247 @@
+package lg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPSink posts the lines in batches to an endpoint, the body being the
+// lines separated by newline, e.g. JSON lines with FormatJSON. A batch is
+// posted when it is full or at every flush interval, and retried with an
+// exponential backoff on errors and 5xx responses.
+type HTTPSink struct {
+	url         string
+	client      *http.Client
+	header      http.Header
+	batchSize   int
+	maxPending  int
+	interval    time.Duration
+	maxRetries  int
+	backoff     time.Duration
+	contentType string
+
+	mu      sync.Mutex
+	pending bytes.Buffer
+	lines   int
+	// sendMu serializes the posts, so that the batches keep their order.
+	sendMu  sync.Mutex
+	dropped atomic.Uint64
+
+	full chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+type HTTPSinkOption func(*HTTPSink)
+
+// WithHTTPBatch sets the count of lines of a batch and the interval at
+// which a batch is posted even if it is not full, 100 and 5s by default.
+func WithHTTPBatch(size int, interval time.Duration) HTTPSinkOption {
+	return func(s *HTTPSink) {
+		s.batchSize = size
+		s.interval = interval
+	}
+}
+
+// WithHTTPRetry sets the count of retries of a batch and the backoff before
+// the first retry, doubled at every retry, 3 and 500ms by default.
+func WithHTTPRetry(maxRetries int, backoff time.Duration) HTTPSinkOption {
+	return func(s *HTTPSink) {
+		s.maxRetries = maxRetries
+		s.backoff = backoff
+	}
+}
+
+// WithHTTPMaxPending sets the count of lines waiting to be posted above
+// which the new lines are dropped, 100 batches by default.
+func WithHTTPMaxPending(lines int) HTTPSinkOption {
+	return func(s *HTTPSink) {
+		s.maxPending = lines
+	}
+}
+
+func WithHTTPClient(client *http.Client) HTTPSinkOption {
+	return func(s *HTTPSink) {
+		s.client = client
+	}
+}
+
+// WithHTTPHeader adds a header to the requests, e.g. an api key.
+func WithHTTPHeader(key, value string) HTTPSinkOption {
+	return func(s *HTTPSink) {
+		s.header.Add(key, value)
+	}
+}
+
+// NewHTTPSink returns a sink posting the lines to url. It should be closed
+// to post the last batch.
+func NewHTTPSink(url string, opts ...HTTPSinkOption) *HTTPSink {
+	s := &HTTPSink{
+		url:         url,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		header:      http.Header{},
+		batchSize:   100,
+		interval:    5 * time.Second,
+		maxRetries:  3,
+		backoff:     500 * time.Millisecond,
+		contentType: "application/x-ndjson",
+		full:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.maxPending <= 0 {
+		s.maxPending = 100 * s.batchSize
+	}
+
+	go s.run()
+	return s
+}
+
+func (s *HTTPSink) WriteLine(_ Level, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lines >= s.maxPending {
+		s.dropped.Add(1)
+		return nil
+	}
+	s.pending.Write(line)
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		s.pending.WriteByte('\n')
+	}
+	s.lines++
+	if s.lines >= s.batchSize {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Dropped returns the count of the lines dropped because too many lines
+// were pending or a batch failed after all the retries.
+func (s *HTTPSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *HTTPSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.full:
+		case <-s.stop:
+			return
+		}
+		s.Flush()
+	}
+}
+
+// take returns the pending lines, at most a batch.
+func (s *HTTPSink) take() ([]byte, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lines == 0 {
+		return nil, 0
+	}
+	data := s.pending.Bytes()
+	n := 0
+	end := 0
+	for n < s.batchSize && end < len(data) {
+		end += bytes.IndexByte(data[end:], '\n') + 1
+		n++
+	}
+	batch := append([]byte(nil), data[:end]...)
+	s.pending.Next(end)
+	s.lines -= n
+	return batch, n
+}
+
+// Flush posts the pending lines.
+func (s *HTTPSink) Flush() error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	for {
+		batch, n := s.take()
+		if n == 0 {
+			return nil
+		}
+		if err := s.post(batch); err != nil {
+			s.dropped.Add(uint64(n))
+			return fmt.Errorf("post %d log lines to %s: %w", n, s.url, err)
+		}
+	}
+}
+
+func (s *HTTPSink) post(batch []byte) error {
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.postOnce(batch)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.maxRetries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-s.stop:
+			// Closing, the last flush is not delayed by the backoff.
+			if attempt > 0 {
+				return err
+			}
+		}
+		backoff *= 2
+	}
+}
+
+// postOnce posts the batch, and reports whether a failure may be retried,
+// which is the case of the errors of the connection and of the 5xx status.
+func (s *HTTPSink) postOnce(batch []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(batch))
+	if err != nil {
+		return false, err
+	}
+	for k, vs := range s.header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", s.contentType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return false, nil
+}
+
+// Close posts the pending lines and stops the background goroutine.
+func (s *HTTPSink) Close() error {
+	select {
+	case <-s.stop:
+		return nil
+	default:
+	}
+	close(s.stop)
+	<-s.done
+	return s.Flush()
+}
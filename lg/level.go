@@ -0,0 +1,320 @@
+package lg
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type Level int
+
+const (
+	TraceLevel Level = iota
+	DebugLevel
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+	// OffLevel disables all the lines, Fatal still exits the process.
+	OffLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case TraceLevel:
+		return "trace"
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	case FatalLevel:
+		return "fatal"
+	case OffLevel:
+		return "off"
+	default:
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "trace":
+		return TraceLevel, nil
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	case "fatal":
+		return FatalLevel, nil
+	case "off", "none":
+		return OffLevel, nil
+	default:
+		return 0, fmt.Errorf("unknown log level: %s", s)
+	}
+}
+
+// levelOverride sets the level of the packages matching pattern.
+type levelOverride struct {
+	pattern string
+	level   Level
+}
+
+// match reports whether the import path of a package is named by the pattern,
+// either the full path, its last elements or one of its parents,
+// e.g. "redisutils" and "github.com/superwhys/goutils" both match
+// "github.com/superwhys/goutils/redisutils".
+func (o levelOverride) match(pkg string) bool {
+	p := o.pattern
+	return pkg == p ||
+		strings.HasSuffix(pkg, "/"+p) ||
+		strings.HasPrefix(pkg, p+"/") ||
+		strings.Contains(pkg, "/"+p+"/")
+}
+
+// levelRules is never changed once stored, a change of the levels stores new rules.
+type levelRules struct {
+	global Level
+	// overrides are sorted by the length of the pattern, the longest first,
+	// so that the most specific one applies.
+	overrides []levelOverride
+	// min and max are the lowest and highest levels of the rules,
+	// a line below min is always dropped and one at max is always written.
+	min, max Level
+	// callers caches the level of every calling pc.
+	callers sync.Map
+}
+
+var (
+	levels  atomic.Pointer[levelRules]
+	levelMu sync.Mutex
+)
+
+func init() {
+	levels.Store(newLevelRules(InfoLevel, nil))
+}
+
+func newLevelRules(global Level, overrides []levelOverride) *levelRules {
+	r := &levelRules{global: global, overrides: overrides, min: global, max: global}
+	sort.SliceStable(r.overrides, func(i, j int) bool {
+		return len(r.overrides[i].pattern) > len(r.overrides[j].pattern)
+	})
+	for _, o := range overrides {
+		if o.level < r.min {
+			r.min = o.level
+		}
+		if o.level > r.max {
+			r.max = o.level
+		}
+	}
+	return r
+}
+
+func (r *levelRules) levelOf(pc uintptr) Level {
+	if len(r.overrides) == 0 {
+		return r.global
+	}
+	if l, ok := r.callers.Load(pc); ok {
+		return l.(Level)
+	}
+
+	level := r.global
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	pkg := funcPackage(frame.Function)
+	for _, o := range r.overrides {
+		if o.match(pkg) {
+			level = o.level
+			break
+		}
+	}
+	r.callers.Store(pc, level)
+	return level
+}
+
+func (r *levelRules) enabled(pc uintptr, level Level) bool {
+	switch {
+	case level < r.min || r.min == OffLevel:
+		return false
+	case level >= r.max && r.max != OffLevel:
+		return true
+	}
+	l := r.levelOf(pc)
+	return l != OffLevel && level >= l
+}
+
+// funcPackage returns the import path of the package of a function,
+// e.g. github.com/superwhys/goutils/redisutils for
+// github.com/superwhys/goutils/redisutils.(*RedisClient).Lock
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
+
+func updateLevels(fn func(global Level, overrides map[string]Level) Level) {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+
+	r := levels.Load()
+	overrides := make(map[string]Level, len(r.overrides))
+	for _, o := range r.overrides {
+		overrides[o.pattern] = o.level
+	}
+	global := fn(r.global, overrides)
+
+	var list []levelOverride
+	for p, l := range overrides {
+		list = append(list, levelOverride{pattern: p, level: l})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].pattern < list[j].pattern })
+	levels.Store(newLevelRules(global, list))
+}
+
+// SetLevel sets the global level, the one of the packages without override.
+func SetLevel(level Level) {
+	updateLevels(func(Level, map[string]Level) Level {
+		return level
+	})
+}
+
+// GetLevel returns the global level.
+func GetLevel() Level {
+	return levels.Load().global
+}
+
+// Enabled reports whether the lines of the level are written for the caller,
+// by the global level and the override of its package. It saves building the
+// costly arguments of a disabled line.
+func Enabled(level Level) bool {
+	r := levels.Load()
+	if level < r.min || level >= r.max {
+		return r.enabled(0, level)
+	}
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+	return r.enabled(pcs[0], level)
+}
+
+// SetPackageLevel overrides the level of the packages matching the pattern,
+// which is an import path, e.g. "github.com/superwhys/goutils/service" for
+// the package and its subpackages, or its last elements, e.g. "redisutils".
+// The most specific pattern applies when several ones match.
+func SetPackageLevel(pattern string, level Level) {
+	pattern = strings.Trim(pattern, "/")
+	updateLevels(func(global Level, overrides map[string]Level) Level {
+		overrides[pattern] = level
+		return global
+	})
+}
+
+// ResetPackageLevel removes the override of the pattern.
+func ResetPackageLevel(pattern string) {
+	pattern = strings.Trim(pattern, "/")
+	updateLevels(func(global Level, overrides map[string]Level) Level {
+		delete(overrides, pattern)
+		return global
+	})
+}
+
+// LevelSpec returns the levels in the form SetLevelSpec accepts.
+func LevelSpec() string {
+	r := levels.Load()
+	parts := []string{r.global.String()}
+	var overrides []string
+	for _, o := range r.overrides {
+		overrides = append(overrides, o.pattern+"="+o.level.String())
+	}
+	sort.Strings(overrides)
+	return strings.Join(append(parts, overrides...), ",")
+}
+
+// SetLevelSpec replaces the levels with the ones of spec, a comma separated
+// list of the global level and package overrides, e.g. "info,redisutils=debug".
+// The global level is info if spec has none.
+func SetLevelSpec(spec string) error {
+	global := InfoLevel
+	overrides := map[string]Level{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pattern, name, ok := strings.Cut(part, "=")
+		if !ok {
+			l, err := ParseLevel(part)
+			if err != nil {
+				return err
+			}
+			global = l
+			continue
+		}
+		l, err := ParseLevel(name)
+		if err != nil {
+			return err
+		}
+		pattern = strings.Trim(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			return fmt.Errorf("missing package of log level: %s", part)
+		}
+		overrides[pattern] = l
+	}
+
+	updateLevels(func(_ Level, current map[string]Level) Level {
+		for k := range current {
+			delete(current, k)
+		}
+		for k, v := range overrides {
+			current[k] = v
+		}
+		return global
+	})
+	return nil
+}
+
+// LevelHandler returns a http.Handler showing the levels on GET and
+// replacing them on PUT or POST with a spec as body, e.g.
+//
+//	curl -X PUT -d 'info,redisutils=debug' http://127.0.0.1:8080/debug/loglevel
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut, http.MethodPost:
+			body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			spec := strings.TrimSpace(string(body))
+			if spec == "" {
+				spec = r.FormValue("level")
+			}
+			if err := SetLevelSpec(spec); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			Infof("Log level changed. spec=%s", LevelSpec())
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, LevelSpec())
+	})
+}
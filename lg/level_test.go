@@ -0,0 +1,122 @@
+package lg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func restoreLevels(t *testing.T) {
+	spec := LevelSpec()
+	t.Cleanup(func() {
+		SetLevelSpec(spec)
+	})
+}
+
+// enabledHere calls allowed like the log functions, for the caller in this package.
+func enabledHere(level Level) bool {
+	return allowed(nil, level, "")
+}
+
+func TestParseLevel(t *testing.T) {
+	for l := TraceLevel; l <= OffLevel; l++ {
+		if got, err := ParseLevel(l.String()); err != nil || got != l {
+			t.Errorf("ParseLevel(%q) = %v, %v", l.String(), got, err)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Errorf("expect error of unknown level")
+	}
+}
+
+func TestLevelSpec(t *testing.T) {
+	restoreLevels(t)
+
+	if err := SetLevelSpec("warn, redisutils=debug,github.com/superwhys/goutils/service=off"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := LevelSpec(), "warn,github.com/superwhys/goutils/service=off,redisutils=debug"; got != want {
+		t.Errorf("spec = %q, want %q", got, want)
+	}
+	if err := SetLevelSpec("info,redisutils=loud"); err == nil {
+		t.Errorf("expect error of unknown level")
+	}
+	if GetLevel() != WarnLevel {
+		t.Errorf("an invalid spec should not change the levels")
+	}
+}
+
+func TestLevelOverride(t *testing.T) {
+	restoreLevels(t)
+
+	tests := []struct {
+		pattern string
+		pkg     string
+		match   bool
+	}{
+		{"redisutils", "github.com/superwhys/goutils/redisutils", true},
+		{"goutils/redisutils", "github.com/superwhys/goutils/redisutils", true},
+		{"github.com/superwhys/goutils", "github.com/superwhys/goutils/redisutils", true},
+		{"service", "github.com/superwhys/goutils/service/finder", true},
+		{"utils", "github.com/superwhys/goutils/redisutils", false},
+		{"redis", "github.com/superwhys/goutils/redisutils", false},
+	}
+	for _, tt := range tests {
+		if got := (levelOverride{pattern: tt.pattern}).match(tt.pkg); got != tt.match {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.pattern, tt.pkg, got, tt.match)
+		}
+	}
+
+	if got := funcPackage("github.com/superwhys/goutils/redisutils.(*RedisClient).Lock"); got != "github.com/superwhys/goutils/redisutils" {
+		t.Errorf("funcPackage = %q", got)
+	}
+
+	SetLevel(WarnLevel)
+	if enabledHere(DebugLevel) {
+		t.Errorf("debug should be disabled by the global level")
+	}
+	SetPackageLevel("goutils/lg", DebugLevel)
+	SetPackageLevel("lg", ErrorLevel)
+	if !enabledHere(DebugLevel) || enabledHere(TraceLevel) {
+		t.Errorf("the most specific override should apply")
+	}
+	if !Enabled(DebugLevel) || Enabled(TraceLevel) {
+		t.Errorf("Enabled should apply the override of the caller")
+	}
+	ResetPackageLevel("goutils/lg")
+	if enabledHere(WarnLevel) || !enabledHere(ErrorLevel) {
+		t.Errorf("the override of lg should apply")
+	}
+	SetLevel(OffLevel)
+	ResetPackageLevel("lg")
+	if enabledHere(FatalLevel) {
+		t.Errorf("off should disable all levels")
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	restoreLevels(t)
+
+	srv := httptest.NewServer(LevelHandler())
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("error,redisutils=trace"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || LevelSpec() != "error,redisutils=trace" {
+		t.Errorf("status = %d, spec = %q", resp.StatusCode, LevelSpec())
+	}
+
+	resp, err = http.Post(srv.URL, "text/plain", strings.NewReader("verbose"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
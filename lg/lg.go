@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
 	"time"
@@ -14,7 +14,6 @@ import (
 )
 
 var (
-	debug  = false
 	logger *Logger
 )
 
@@ -26,14 +25,50 @@ func SetDefaultLoggerOutput(stdout, stderr io.Writer) {
 	logger.SetLoggerOutput(stdout, stderr)
 }
 
+func SetDefaultLoggerFormat(format Format) {
+	logger.SetFormat(format)
+}
+
+// SetDefaultSlogHandler routes the lines of the default logger through h,
+// e.g. a slog.JSONHandler shared with the libraries logging with slog.
+// A nil h restores the outputs of the logger.
+func SetDefaultSlogHandler(h slog.Handler) {
+	logger.SetSlogHandler(h)
+}
+
+// EnableAsync makes the default logger write asynchronously, see Logger.SetAsync.
+func EnableAsync(size int, policy AsyncPolicy) {
+	logger.SetAsync(size, policy)
+}
+
+// Flush waits until the lines buffered by the async writer of the default
+// logger are written. It should be called before the process exits.
+func Flush() {
+	logger.Flush()
+}
+
+// GetAsyncStats returns the counters of the async writer of the default logger.
+func GetAsyncStats() AsyncStats {
+	return logger.AsyncStats()
+}
+
+// IsDebug reports whether the global level enables the debug lines.
 func IsDebug() bool {
-	return debug
+	return GetLevel() <= DebugLevel
 }
 
+// EnableDebug lowers the global level to debug.
 func EnableDebug() {
-	debug = true
+	updateLevels(func(global Level, _ map[string]Level) Level {
+		if global > DebugLevel {
+			return DebugLevel
+		}
+		return global
+	})
 }
 
+// EnableLogToFile writes the log to the rotated file of the config, and
+// the error and fatal lines to ErrorFileName if it is set.
 func EnableLogToFile(logConf *shared.LogConfig) {
 	shared.PtrLogConfig = logConf
 	logger := &lumberjack.Logger{
@@ -43,20 +78,51 @@ func EnableLogToFile(logConf *shared.LogConfig) {
 		MaxAge:     logConf.MaxAge,
 		Compress:   logConf.Compress,
 	}
+	errLogger := logger
+	if logConf.ErrorFileName != "" && logConf.ErrorFileName != logConf.FileName {
+		errLogger = &lumberjack.Logger{
+			Filename:   logConf.ErrorFileName,
+			MaxSize:    logConf.MaxSize,
+			MaxBackups: logConf.MaxBackup,
+			MaxAge:     logConf.MaxAge,
+			Compress:   logConf.Compress,
+		}
+	}
 
 	Infof("set logger to file: %v", logConf.FileName)
-	SetDefaultLoggerOutput(logger, logger)
+	if logConf.Format != "" {
+		if format, err := ParseFormat(logConf.Format); err != nil {
+			Error(err)
+		} else {
+			SetDefaultLoggerFormat(format)
+		}
+	}
+	SetDefaultLoggerOutput(logger, errLogger)
 }
 
-func doLog(log *log.Logger, msg string) {
-	for _, line := range strings.Split(msg, "\n") {
-		log.Output(3, line)
-	}
+// AddSink fans the lines of the default logger out to s, see Logger.AddSink.
+func AddSink(s Sink, level Level, format Format) {
+	logger.AddSink(s, level, format)
+}
+
+// SinkErrors returns the count of the lines the sinks of the default logger
+// failed to write.
+func SinkErrors() uint64 {
+	return logger.SinkErrors()
+}
+
+// CloseSinks closes the sinks of the default logger.
+func CloseSinks() error {
+	return logger.CloseSinks()
+}
+
+func doLog(level Level, msg string) {
+	logger.output(3, level, msg, nil)
 }
 
 func Error(v ...interface{}) {
-	if v[0] != nil {
-		doLog(logger.errLog, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+	if v[0] != nil && allowed(nil, ErrorLevel, "") {
+		doLog(ErrorLevel, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
 	}
 }
 
@@ -68,40 +134,55 @@ func PanicError(err error, msg ...interface{}) {
 		} else {
 			s = err.Error()
 		}
-		doLog(logger.errLog, s)
+		if allowed(nil, ErrorLevel, "") {
+			doLog(ErrorLevel, s)
+		}
+		Flush()
 		panic(err)
 	}
 }
 
 func Warn(v ...interface{}) {
-	if v[0] != nil {
-		doLog(logger.warnLog, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+	if v[0] != nil && allowed(nil, WarnLevel, "") {
+		doLog(WarnLevel, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
 	}
 }
 
 func Info(v ...interface{}) {
-	if v[0] != nil {
-		doLog(logger.infoLog, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+	if v[0] != nil && allowed(nil, InfoLevel, "") {
+		doLog(InfoLevel, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
 	}
 }
 
 func Debug(v ...interface{}) {
-	if debug && v[0] != nil {
-		doLog(logger.debugLog, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+	if v[0] != nil && allowed(nil, DebugLevel, "") {
+		doLog(DebugLevel, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
 	}
 }
 
+func Trace(v ...interface{}) {
+	if v[0] != nil && allowed(nil, TraceLevel, "") {
+		doLog(TraceLevel, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+	}
+}
+
+// Fatal writes the line unless the level is off, then exits the process.
 func Fatal(v ...interface{}) {
-	var msg []string
-	for _, i := range v {
-		msg = append(msg, fmt.Sprintf("%v", i))
+	if allowed(nil, FatalLevel, "") {
+		var msg []string
+		for _, i := range v {
+			msg = append(msg, fmt.Sprintf("%v", i))
+		}
+		doLog(FatalLevel, strings.Join(msg, " "))
 	}
-	doLog(logger.fatalLog, strings.Join(msg, " "))
+	Flush()
 	os.Exit(1)
 }
 
+// Jsonify returns the indented json of v.
+// String fields tagged with `secret:"true"` are redacted.
 func Jsonify(v interface{}) string {
-	d, err := json.MarshalIndent(v, "", "  ")
+	d, err := json.MarshalIndent(redactValue(v), "", "  ")
 	if err != nil {
 		Error(err)
 		panic(err)
@@ -110,38 +191,64 @@ func Jsonify(v interface{}) string {
 }
 
 func Errorf(msg string, v ...interface{}) {
+	if !allowed(nil, ErrorLevel, msg) {
+		return
+	}
+
 	var s string
 	if len(v) != 0 {
 		s = strings.TrimSuffix(fmt.Sprintf(msg, v...), "\n")
 	} else {
 		s = msg
 	}
-	doLog(logger.errLog, strings.TrimSuffix(s, "\n"))
+	doLog(ErrorLevel, strings.TrimSuffix(s, "\n"))
 
 }
 
 func Warnf(msg string, v ...interface{}) {
+	if !allowed(nil, WarnLevel, msg) {
+		return
+	}
+
 	var s string
 	if len(v) != 0 {
 		s = strings.TrimSuffix(fmt.Sprintf(msg, v...), "\n")
 	} else {
 		s = msg
 	}
-	doLog(logger.warnLog, s)
+	doLog(WarnLevel, s)
 }
 
 func Infof(msg string, v ...interface{}) {
+	if !allowed(nil, InfoLevel, msg) {
+		return
+	}
+
 	var s string
 	if len(v) != 0 {
 		s = strings.TrimSuffix(fmt.Sprintf(msg, v...), "\n")
 	} else {
 		s = msg
 	}
-	doLog(logger.infoLog, s)
+	doLog(InfoLevel, s)
 }
 
 func Debugf(msg string, v ...interface{}) {
-	if !debug {
+	if !allowed(nil, DebugLevel, msg) {
+		return
+	}
+
+	var s string
+	if len(v) != 0 {
+		s = strings.TrimSuffix(fmt.Sprintf(msg, v...), "\n")
+	} else {
+		s = msg
+	}
+	doLog(DebugLevel, s)
+}
+
+func Tracef(msg string, v ...interface{}) {
+	if !allowed(nil, TraceLevel, msg) {
 		return
 	}
 
@@ -151,7 +258,7 @@ func Debugf(msg string, v ...interface{}) {
 	} else {
 		s = msg
 	}
-	doLog(logger.debugLog, s)
+	doLog(TraceLevel, s)
 }
 
 // TimeFuncDuration returns the duration consumed by function.
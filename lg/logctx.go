@@ -15,6 +15,8 @@ const (
 	logContextKey = "logContext"
 )
 
+var verbRe = regexp.MustCompile("%[^%]+")
+
 type LogContext struct {
 	msg    []string
 	keys   []string
@@ -51,8 +53,7 @@ func parseFmtStr(format string) (msg string, isKV []bool, keys, descs []string)
 		}
 		idx := strings.Index(s, "=%")
 		if idx == -1 || strings.Contains(s[:idx], "=") {
-			re, _ := regexp.Compile("%[^%]+")
-			matches := re.FindAllStringIndex(s, -1)
+			matches := verbRe.FindAllStringIndex(s, -1)
 			for i := 0; i < len(matches); i++ {
 				isKV = append(isKV, false)
 			}
@@ -164,50 +165,66 @@ func (lc *LogContext) LogFmt() string {
 	return msg + " " + color.MagentaString(str)
 }
 
-type logable interface {
-	Output(calldepth int, s string) error
-}
-
-func logc(ctx context.Context, l logable) {
+func logc(ctx context.Context, level Level) {
 	lc := ParseFromContext(ctx)
 	if lc == nil {
 		return
 	}
 
-	msg := lc.LogFmt()
-	for _, line := range strings.Split(msg, "\n") {
-		l.Output(3, line)
-	}
+	logger.output(3, level, "", lc)
 }
 
 func Infoc(ctx context.Context, msg string, v ...interface{}) {
+	if !allowed(ctx, InfoLevel, msg) {
+		return
+	}
+
 	if len(msg) > 0 || len(v) > 0 {
 		ctx = With(ctx, msg, v...)
 	}
-	logc(ctx, logger.infoLog)
+	logc(ctx, InfoLevel)
 }
 
 func Debugc(ctx context.Context, msg string, v ...interface{}) {
-	if !debug {
+	if !allowed(ctx, DebugLevel, msg) {
 		return
 	}
 
 	if len(msg) > 0 || len(v) > 0 {
 		ctx = With(ctx, msg, v...)
 	}
-	logc(ctx, logger.debugLog)
+	logc(ctx, DebugLevel)
 }
 
 func Errorc(ctx context.Context, msg string, v ...interface{}) {
+	if !allowed(ctx, ErrorLevel, msg) {
+		return
+	}
+
 	if len(msg) > 0 || len(v) > 0 {
 		ctx = With(ctx, msg, v...)
 	}
-	logc(ctx, logger.errLog)
+	logc(ctx, ErrorLevel)
 }
 
 func Warnc(ctx context.Context, msg string, v ...interface{}) {
+	if !allowed(ctx, WarnLevel, msg) {
+		return
+	}
+
+	if len(msg) > 0 || len(v) > 0 {
+		ctx = With(ctx, msg, v...)
+	}
+	logc(ctx, WarnLevel)
+}
+
+func Tracec(ctx context.Context, msg string, v ...interface{}) {
+	if !allowed(ctx, TraceLevel, msg) {
+		return
+	}
+
 	if len(msg) > 0 || len(v) > 0 {
 		ctx = With(ctx, msg, v...)
 	}
-	logc(ctx, logger.warnLog)
+	logc(ctx, TraceLevel)
 }
@@ -1,20 +1,106 @@
 package lg
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/go-logfmt/logfmt"
+	"github.com/mattn/go-isatty"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// Format is the format of the log lines.
+type Format string
+
+const (
+	// FormatText is the human readable format, colored on terminals.
+	FormatText Format = "text"
+	// FormatLogfmt writes every line as logfmt key=value pairs.
+	FormatLogfmt Format = "logfmt"
+	// FormatJSON writes every line as a json object.
+	FormatJSON Format = "json"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatText, FormatLogfmt, FormatJSON:
+		return f, nil
+	case "":
+		return FormatText, nil
+	default:
+		return "", fmt.Errorf("unknown log format: %s", s)
+	}
+}
+
+const (
+	noCaller = iota
+	shortCaller
+	longCaller
+)
+
+type levelStyle struct {
+	prefix string
+	color  *color.Color
+	// caller is how the caller is printed in text format.
+	caller int
+	stderr bool
+}
+
+var levelStyles = map[Level]levelStyle{
+	TraceLevel: {prefix: "[TRACE]", color: color.New(color.FgBlue), caller: shortCaller},
+	DebugLevel: {prefix: "[DEBUG]", color: color.New(color.FgCyan), caller: shortCaller},
+	InfoLevel:  {prefix: "[INFO]", color: color.New(color.FgGreen)},
+	WarnLevel:  {prefix: "[WARN]", color: color.New(color.FgYellow)},
+	ErrorLevel: {prefix: "[ERROR]", color: color.New(color.FgRed), caller: shortCaller, stderr: true},
+	FatalLevel: {prefix: "[FATAL]", color: color.New(color.FgRed), caller: longCaller, stderr: true},
+}
+
+var kvColor = color.New(color.FgMagenta)
+
+// entry is a single log line before formatting.
+type entry struct {
+	time   time.Time
+	level  Level
+	pc     uintptr
+	file   string
+	line   int
+	msg    string
+	keys   []string
+	values []string
+}
+
 type Logger struct {
-	infoLog  *log.Logger
-	debugLog *log.Logger
-	warnLog  *log.Logger
-	errLog   *log.Logger
-	fatalLog *log.Logger
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	stdout io.Writer
+	stderr io.Writer
+	format Format
+
+	// color is nil if it is detected from the outputs.
+	color       *bool
+	stdoutColor bool
+	stderrColor bool
+
+	// handler is the slog.Handler the lines are routed to instead of the outputs.
+	handler slog.Handler
+	// async queues the lines written by a background goroutine if set.
+	async *asyncWriter
+	// sinks receive the lines besides the outputs.
+	sinks []*sinkEntry
+	// sinkErrors counts the lines the sinks failed to write.
+	sinkErrors atomic.Uint64
 }
 
 type Option func(*Logger)
@@ -32,17 +118,32 @@ func WithFileOption(filename string, maxSize, maxBackup, maxAge int, logCompress
 	}
 }
 
-func New(options ...Option) *Logger {
-	var stdout io.Writer = os.Stdout
-	var stderr io.Writer = os.Stderr
+func WithFormat(format Format) Option {
+	return func(l *Logger) {
+		l.SetFormat(format)
+	}
+}
 
-	l := &Logger{
-		infoLog:  log.New(stdout, color.GreenString("[INFO]"), log.LstdFlags|log.LUTC),
-		debugLog: log.New(stdout, color.CyanString("[DEBUG]"), log.LstdFlags|log.Lshortfile|log.LUTC),
-		errLog:   log.New(stderr, color.RedString("[ERROR]"), log.LstdFlags|log.Lshortfile|log.LUTC),
-		warnLog:  log.New(stdout, color.YellowString("[WARN]"), log.LstdFlags|log.LUTC),
-		fatalLog: log.New(stderr, color.RedString("[FATAL]"), log.LstdFlags|log.Llongfile|log.LUTC),
+// WithColor forces the colors of text format on or off. By default colors
+// are only used when the output is a terminal.
+func WithColor(enable bool) Option {
+	return func(l *Logger) {
+		l.color = &enable
+		l.SetLoggerOutput(l.stdout, l.stderr)
 	}
+}
+
+// WithSlogHandler routes the log lines through h instead of
+// writing them to the outputs of the logger.
+func WithSlogHandler(h slog.Handler) Option {
+	return func(l *Logger) {
+		l.SetSlogHandler(h)
+	}
+}
+
+func New(options ...Option) *Logger {
+	l := &Logger{format: FormatText}
+	l.SetLoggerOutput(os.Stdout, os.Stderr)
 
 	for _, opt := range options {
 		opt(l)
@@ -52,9 +153,231 @@ func New(options ...Option) *Logger {
 }
 
 func (l *Logger) SetLoggerOutput(stdout, stderr io.Writer) {
-	l.infoLog.SetOutput(stdout)
-	l.debugLog.SetOutput(stdout)
-	l.errLog.SetOutput(stderr)
-	l.warnLog.SetOutput(stdout)
-	l.fatalLog.SetOutput(stderr)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stdout = stdout
+	l.stderr = stderr
+	l.stdoutColor = l.colorEnabled(stdout)
+	l.stderrColor = l.colorEnabled(stderr)
+}
+
+func (l *Logger) SetFormat(format Format) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.format = format
+}
+
+// SetSlogHandler routes the log lines through h, the LogContext keys becoming
+// the attrs of the records. A nil h, or the SlogHandler of l itself, makes l
+// write to its outputs again.
+func (l *Logger) SetSlogHandler(h slog.Handler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sh, ok := h.(*SlogHandler); ok && sh.logger == l {
+		h = nil
+	}
+	l.handler = h
+}
+
+func (l *Logger) colorEnabled(w io.Writer) bool {
+	if l.color != nil {
+		return *l.color
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		// e.g. the lumberjack file
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// isColored reports whether the text written to stdout is colored.
+func (l *Logger) isColored() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.handler == nil && l.format == FormatText && l.stdoutColor
+}
+
+// output writes a log line. calldepth is the count of frames to skip to find
+// the caller, 1 is the caller of output.
+func (l *Logger) output(calldepth int, level Level, msg string, lc *LogContext) {
+	var pcs [1]uintptr
+	runtime.Callers(calldepth+1, pcs[:])
+	e := &entry{
+		time:  time.Now(),
+		level: level,
+		pc:    pcs[0],
+		msg:   msg,
+	}
+	if lc != nil {
+		e.msg = strings.Join(lc.msg, " ")
+		e.keys = lc.keys
+		e.values = lc.values
+	}
+	l.log(e)
+}
+
+func (l *Logger) log(e *entry) {
+	redaction.Load().entry(e)
+
+	l.mu.Lock()
+	if h := l.handler; h != nil {
+		l.mu.Unlock()
+		handleSlog(h, e)
+		return
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{e.pc}).Next()
+	e.file, e.line = frame.File, frame.Line
+	if e.file == "" {
+		e.file = "???"
+	}
+
+	w, colored := l.stdout, l.stdoutColor
+	if levelStyles[e.level].stderr {
+		w, colored = l.stderr, l.stderrColor
+	}
+
+	l.buf.Reset()
+	formatEntry(&l.buf, e, l.format, colored)
+	l.writeLine(w, e.level, l.buf.Bytes())
+	sinks, async := l.sinks, l.async
+	l.mu.Unlock()
+
+	// The sinks, e.g. a remote syslog, are written without holding the lock,
+	// so that a slow sink does not stall the outputs.
+	for _, s := range sinks {
+		if e.level >= s.level {
+			s.write(e, async)
+		}
+	}
+}
+
+func (l *Logger) writeLine(w io.Writer, level Level, line []byte) {
+	if l.async != nil {
+		l.async.write(w, level, line)
+		return
+	}
+	w.Write(line)
+}
+
+func formatEntry(buf *bytes.Buffer, e *entry, format Format, colored bool) {
+	switch format {
+	case FormatJSON:
+		formatJSON(buf, e)
+	case FormatLogfmt:
+		formatLogfmt(buf, e)
+	default:
+		formatText(buf, e, colored)
+	}
+}
+
+func formatText(buf *bytes.Buffer, e *entry, colored bool) {
+	style := levelStyles[e.level]
+	prefix := style.prefix
+	if colored {
+		prefix = style.color.Sprint(prefix)
+	}
+	header := prefix + e.time.UTC().Format("2006/01/02 15:04:05") + " "
+	switch style.caller {
+	case shortCaller:
+		header += filepath.Base(e.file) + ":" + strconv.Itoa(e.line) + ": "
+	case longCaller:
+		header += e.file + ":" + strconv.Itoa(e.line) + ": "
+	}
+
+	var kv string
+	if len(e.keys) > 0 {
+		var kvBuf bytes.Buffer
+		encoder := logfmt.NewEncoder(&kvBuf)
+		for i := range e.keys {
+			encoder.EncodeKeyval(e.keys[i], e.values[i])
+		}
+		kv = kvBuf.String()
+		if colored {
+			kv = kvColor.Sprint(kv)
+		}
+	}
+
+	lines := strings.Split(e.msg, "\n")
+	for i, line := range lines {
+		buf.WriteString(header)
+		buf.WriteString(line)
+		if i == len(lines)-1 && kv != "" {
+			if line != "" {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(kv)
+		}
+		buf.WriteByte('\n')
+	}
+}
+
+func (e *entry) caller() string {
+	return filepath.Base(e.file) + ":" + strconv.Itoa(e.line)
+}
+
+func (e *entry) timestamp() string {
+	return e.time.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// fieldKey renames the keys of LogContext colliding with the builtin fields.
+func fieldKey(key string) string {
+	switch key {
+	case "ts", "level", "caller", "msg":
+		return "ctx." + key
+	}
+	return key
+}
+
+func formatLogfmt(buf *bytes.Buffer, e *entry) {
+	encoder := logfmt.NewEncoder(buf)
+	encoder.EncodeKeyvals("ts", e.timestamp(), "level", e.level.String(), "caller", e.caller(), "msg", e.msg)
+	for i := range e.keys {
+		encoder.EncodeKeyval(fieldKey(e.keys[i]), e.values[i])
+	}
+	encoder.EndRecord()
+}
+
+// marshalJSON is json.Marshal without escaping <, > and &, which are common in log values.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func writeJSONField(buf *bytes.Buffer, key string, value interface{}) {
+	k, _ := marshalJSON(key)
+	v, err := marshalJSON(value)
+	if err != nil {
+		v, _ = marshalJSON(fmt.Sprint(value))
+	}
+	buf.WriteByte(',')
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+}
+
+func formatJSON(buf *bytes.Buffer, e *entry) {
+	ts, _ := marshalJSON(e.timestamp())
+	buf.WriteString(`{"ts":`)
+	buf.Write(ts)
+	writeJSONField(buf, "level", e.level.String())
+	writeJSONField(buf, "caller", e.caller())
+	writeJSONField(buf, "msg", e.msg)
+	for i := range e.keys {
+		writeJSONField(buf, fieldKey(e.keys[i]), e.values[i])
+	}
+	buf.WriteString("}\n")
 }
@@ -0,0 +1,62 @@
+package lg
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLoggerFormat(t *testing.T) {
+	lc := &LogContext{}
+	lc.msg = []string{"hello"}
+	lc.keys = []string{"traceId", "level"}
+	lc.values = []string{"abc", "x"}
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := New(WithFormat(FormatJSON))
+		l.SetLoggerOutput(&buf, &buf)
+		l.output(1, InfoLevel, "", lc)
+
+		var fields map[string]string
+		if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+			t.Fatalf("unmarshal %q: %v", buf.String(), err)
+		}
+		for k, v := range map[string]string{"level": "info", "msg": "hello", "traceId": "abc", "ctx.level": "x"} {
+			if fields[k] != v {
+				t.Errorf("field %s = %q, want %q", k, fields[k], v)
+			}
+		}
+		if !strings.HasPrefix(fields["caller"], "logger_test.go:") {
+			t.Errorf("caller = %q", fields["caller"])
+		}
+	})
+
+	t.Run("logfmt", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := New(WithFormat(FormatLogfmt))
+		l.SetLoggerOutput(&buf, &buf)
+		l.output(1, WarnLevel, "multi word", nil)
+
+		line := buf.String()
+		if !strings.Contains(line, `level=warn`) || !strings.Contains(line, `msg="multi word"`) {
+			t.Errorf("unexpected line: %q", line)
+		}
+	})
+
+	t.Run("text without color", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := New()
+		l.SetLoggerOutput(&buf, &buf)
+		l.output(1, ErrorLevel, "", lc)
+
+		line := buf.String()
+		if strings.Contains(line, "\x1b[") {
+			t.Errorf("unexpected color in %q", line)
+		}
+		if !strings.HasPrefix(line, "[ERROR]") || !strings.HasSuffix(line, "hello traceId=abc level=x\n") {
+			t.Errorf("unexpected line: %q", line)
+		}
+	})
+}
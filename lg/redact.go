@@ -0,0 +1,320 @@
+package lg
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	Redacted = "******"
+
+	// maxRedactDepth stops walking cyclic values, which json would reject anyway.
+	maxRedactDepth = 32
+)
+
+// DefaultRedactKeys are the key names whose values are redacted by default.
+var DefaultRedactKeys = []string{"password", "passwd", "token", "authorization", "secret"}
+
+var (
+	jwtPattern    = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+	bearerPattern = regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]+=*)`)
+	// cardPattern matches the numbers of the common card networks, which are
+	// checked by the Luhn algorithm to spare the other long numbers.
+	cardPattern = regexp.MustCompile(`\b[3-6]\d(?:[ -]?\d){11,17}\b`)
+)
+
+type redactRule struct {
+	re    *regexp.Regexp
+	valid func(match string) bool
+}
+
+// redactor redacts the lines before they are formatted. It is never
+// changed once stored, a change of the config stores a new one.
+type redactor struct {
+	// keys are the lower case key names, matching the keys containing them.
+	keys []string
+	// rules are applied to the message and the values, the key value
+	// pairs written in the message first.
+	rules []redactRule
+	// custom are the patterns added by AddRedactPatterns.
+	custom []*regexp.Regexp
+}
+
+var (
+	redaction atomic.Pointer[redactor]
+	redactMu  sync.Mutex
+)
+
+func init() {
+	SetRedactKeys(DefaultRedactKeys...)
+}
+
+func newRedactor(keys []string, custom []*regexp.Regexp) *redactor {
+	r := &redactor{custom: custom}
+	var quoted []string
+	for _, k := range keys {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			r.keys = append(r.keys, k)
+			quoted = append(quoted, regexp.QuoteMeta(k))
+		}
+	}
+	if len(quoted) > 0 {
+		// e.g. password=xxx, "token": "xxx" or Authorization: xxx
+		keyValue := regexp.MustCompile(`(?i)[\w.-]*(?:` + strings.Join(quoted, "|") + `)[\w.-]*"?\s*[=:]\s*(?:bearer\s+)?(?:"([^"]*)"|([^\s"&,;]+))`)
+		r.rules = append(r.rules, redactRule{re: keyValue})
+	}
+	r.rules = append(r.rules,
+		redactRule{re: bearerPattern},
+		redactRule{re: jwtPattern},
+		redactRule{re: cardPattern, valid: luhnValid},
+	)
+	for _, re := range custom {
+		r.rules = append(r.rules, redactRule{re: re})
+	}
+	return r
+}
+
+func updateRedaction(fn func(keys []string, custom []*regexp.Regexp) ([]string, []*regexp.Regexp)) {
+	redactMu.Lock()
+	defer redactMu.Unlock()
+
+	var keys []string
+	var custom []*regexp.Regexp
+	if r := redaction.Load(); r != nil {
+		keys = append(keys, r.keys...)
+		custom = append(custom, r.custom...)
+	}
+	redaction.Store(newRedactor(fn(keys, custom)))
+}
+
+// SetRedactKeys replaces the key names whose values are redacted, matching
+// case-insensitively the keys containing them, e.g. "token" matches
+// "access_token". They apply to the keys of the LogContext and to the
+// key value pairs in the message text like password=xxx.
+func SetRedactKeys(keys ...string) {
+	updateRedaction(func(_ []string, custom []*regexp.Regexp) ([]string, []*regexp.Regexp) {
+		return keys, custom
+	})
+}
+
+// AddRedactKeys adds key names to redact, see SetRedactKeys.
+func AddRedactKeys(keys ...string) {
+	updateRedaction(func(current []string, custom []*regexp.Regexp) ([]string, []*regexp.Regexp) {
+		return append(current, keys...), custom
+	})
+}
+
+// AddRedactPatterns redacts the matches of the patterns in the message and
+// the values, besides the JWTs, bearer tokens and card numbers redacted by
+// default. If a pattern has groups, only the matched groups are redacted.
+func AddRedactPatterns(patterns ...*regexp.Regexp) {
+	updateRedaction(func(keys []string, current []*regexp.Regexp) ([]string, []*regexp.Regexp) {
+		return keys, append(current, patterns...)
+	})
+}
+
+// RedactString returns s with the sensitive data redacted like the log lines.
+func RedactString(s string) string {
+	return redaction.Load().text(s)
+}
+
+func (r *redactor) isKey(key string) bool {
+	if len(r.keys) == 0 {
+		return false
+	}
+	key = strings.ToLower(key)
+	for _, k := range r.keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *redactor) text(s string) string {
+	for _, rule := range r.rules {
+		s = redactMatches(s, rule)
+	}
+	return s
+}
+
+// entry redacts the message and the values of e. The slices of e may be
+// shared with a LogContext, so they are copied before any change.
+func (r *redactor) entry(e *entry) {
+	e.msg = r.text(e.msg)
+	copied := false
+	for i, key := range e.keys {
+		v := e.values[i]
+		if r.isKey(key) {
+			if v != "" {
+				v = Redacted
+			}
+		} else {
+			v = r.text(v)
+		}
+		if v == e.values[i] {
+			continue
+		}
+		if !copied {
+			e.values = sliceClone(e.values)
+			copied = true
+		}
+		e.values[i] = v
+	}
+}
+
+// redactMatches replaces the matches of the rule, or their non-empty groups, by Redacted.
+func redactMatches(s string, rule redactRule) string {
+	matches := rule.re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if rule.valid != nil && !rule.valid(s[m[0]:m[1]]) {
+			continue
+		}
+		spans := [][2]int{}
+		for g := 2; g+1 < len(m); g += 2 {
+			if m[g] >= 0 && m[g+1] > m[g] {
+				spans = append(spans, [2]int{m[g], m[g+1]})
+			}
+		}
+		if len(m) == 2 {
+			spans = append(spans, [2]int{m[0], m[1]})
+		}
+		for _, span := range spans {
+			b.WriteString(s[last:span[0]])
+			b.WriteString(Redacted)
+			last = span[1]
+		}
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum.
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// isRedactField reports whether the value of the field must not be printed.
+func isRedactField(f reflect.StructField) bool {
+	return f.Tag.Get("secret") == "true" || f.Tag.Get("log") == "redact"
+}
+
+// redactValue returns a copy of v in which the fields tagged with
+// `secret:"true"` or `log:"redact"` are replaced by Redacted, or by their
+// zero value if they are not strings. v itself is never modified.
+func redactValue(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return v
+	}
+	return redactCopy(rv, 0).Interface()
+}
+
+func redactCopy(rv reflect.Value, depth int) reflect.Value {
+	if depth > maxRedactDepth {
+		return rv
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return rv
+		}
+		elem := redactCopy(rv.Elem(), depth+1)
+		ptr := reflect.New(elem.Type())
+		ptr.Elem().Set(elem)
+		return ptr
+	case reflect.Interface:
+		if rv.IsNil() {
+			return rv
+		}
+		nv := reflect.New(rv.Type()).Elem()
+		nv.Set(redactCopy(rv.Elem(), depth+1))
+		return nv
+	case reflect.Struct:
+		nv := reflect.New(rv.Type()).Elem()
+		nv.Set(rv)
+		for i := 0; i < rv.NumField(); i++ {
+			f := rv.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			fv := nv.Field(i)
+			if isRedactField(f) {
+				if fv.Kind() != reflect.String {
+					fv.Set(reflect.Zero(fv.Type()))
+				} else if fv.String() != "" {
+					fv.SetString(Redacted)
+				}
+				continue
+			}
+			fv.Set(redactCopy(rv.Field(i), depth+1))
+		}
+		return nv
+	case reflect.Slice:
+		if rv.IsNil() || !mayRedact(rv.Type().Elem()) {
+			return rv
+		}
+		nv := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			nv.Index(i).Set(redactCopy(rv.Index(i), depth+1))
+		}
+		return nv
+	case reflect.Array:
+		if !mayRedact(rv.Type().Elem()) {
+			return rv
+		}
+		nv := reflect.New(rv.Type()).Elem()
+		for i := 0; i < rv.Len(); i++ {
+			nv.Index(i).Set(redactCopy(rv.Index(i), depth+1))
+		}
+		return nv
+	case reflect.Map:
+		if rv.IsNil() || !mayRedact(rv.Type().Elem()) {
+			return rv
+		}
+		nv := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			nv.SetMapIndex(iter.Key(), redactCopy(iter.Value(), depth+1))
+		}
+		return nv
+	}
+	return rv
+}
+
+// mayRedact reports whether values of type t can hold a field to redact.
+func mayRedact(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
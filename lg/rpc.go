@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
@@ -42,3 +44,34 @@ func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.S
 
 	return err
 }
+
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	td := TimeFuncDuration()
+
+	ctx = With(ctx, fmt.Sprintf("[%s]", strings.TrimPrefix(method, "/")))
+
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	duration := td()
+	if err != nil {
+		Infoc(ctx, "Failed to call method %s call_time=%s code=%s call_err=%s", method, duration, status.Code(err), err)
+	} else {
+		Infoc(ctx, "Succeed to call method %s call_time=%s code=%s", method, duration, codes.OK)
+	}
+	return err
+}
+
+// StreamClientInterceptor logs the creation of the streams, not their messages.
+func StreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	td := TimeFuncDuration()
+
+	ctx = With(ctx, fmt.Sprintf("[%s]", strings.TrimPrefix(method, "/")))
+
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	duration := td()
+	if err != nil {
+		Infoc(ctx, "Failed to open stream method %s call_time=%s code=%s call_err=%s", method, duration, status.Code(err), err)
+	} else {
+		Infoc(ctx, "Succeed to open stream method %s call_time=%s", method, duration)
+	}
+	return stream, err
+}
@@ -0,0 +1,185 @@
+package lg
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const sampledContextKey = "logSampled"
+
+// Sampling limits the lines of a message template: the First lines of every
+// Interval are written, then 1 in Thereafter, and a summary line counting the
+// suppressed ones is written when the interval ends. The template of a line is
+// its format string, or its call site for the functions without format.
+type Sampling struct {
+	First int
+	// Thereafter is M of the 1 in M lines written after the first ones,
+	// 0 suppresses them all.
+	Thereafter int
+	Interval   time.Duration
+}
+
+// DefaultSampling is used by Sampled without sampling.
+var DefaultSampling = Sampling{First: 5, Thereafter: 100, Interval: time.Minute}
+
+var (
+	globalSampling atomic.Pointer[Sampling]
+	sampler        = &lineSampler{counters: map[sampleKey]*sampleCounter{}}
+)
+
+// SetSampling samples all the lines but the fatal ones, nil disables it.
+func SetSampling(s *Sampling) {
+	if s != nil {
+		clone := *s
+		s = &clone
+	}
+	globalSampling.Store(s)
+}
+
+// Sampled returns a copy of ctx whose lines are sampled, by the given
+// sampling or else by the global one if set or DefaultSampling. It is meant
+// for the call sites which may flood the log, e.g. a retry loop:
+//
+//	lg.Errorc(lg.Sampled(ctx), "blpop err(maybe timeout): %v", err)
+func Sampled(ctx context.Context, sampling ...Sampling) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var s Sampling
+	switch {
+	case len(sampling) > 0:
+		s = sampling[0]
+	case globalSampling.Load() != nil:
+		s = *globalSampling.Load()
+	default:
+		s = DefaultSampling
+	}
+	return context.WithValue(ctx, sampledContextKey, &s)
+}
+
+func samplingOf(ctx context.Context) *Sampling {
+	if ctx != nil {
+		if s, ok := ctx.Value(sampledContextKey).(*Sampling); ok {
+			return s
+		}
+	}
+	return globalSampling.Load()
+}
+
+type sampleKey struct {
+	pc    uintptr
+	tmpl  string
+	level Level
+}
+
+type sampleCounter struct {
+	start      time.Time
+	interval   time.Duration
+	count      int
+	suppressed int
+}
+
+type lineSampler struct {
+	mu        sync.Mutex
+	counters  map[sampleKey]*sampleCounter
+	lastSweep time.Time
+}
+
+func (s *lineSampler) allow(key sampleKey, conf *Sampling) bool {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= conf.Interval {
+		s.sweep(now)
+	}
+
+	c := s.counters[key]
+	if c == nil || now.Sub(c.start) >= conf.Interval {
+		// The summary of the previous counter is written by its timer.
+		c = &sampleCounter{start: now, interval: conf.Interval}
+		s.counters[key] = c
+	}
+
+	c.count++
+	if c.count <= conf.First {
+		return true
+	}
+	if conf.Thereafter > 0 && (c.count-conf.First)%conf.Thereafter == 0 {
+		return true
+	}
+
+	c.suppressed++
+	if c.suppressed == 1 {
+		time.AfterFunc(c.start.Add(conf.Interval).Sub(now), func() {
+			s.summarize(key, c, conf.Interval)
+		})
+	}
+	return false
+}
+
+// sweep deletes the counters whose interval ended without suppressing any
+// line, which have no summary to delete them, e.g. of the messages logged
+// only once.
+func (s *lineSampler) sweep(now time.Time) {
+	s.lastSweep = now
+	for key, c := range s.counters {
+		if c.suppressed == 0 && now.Sub(c.start) >= c.interval {
+			delete(s.counters, key)
+		}
+	}
+}
+
+// summarize writes the count of the lines suppressed in the interval of c.
+func (s *lineSampler) summarize(key sampleKey, c *sampleCounter, interval time.Duration) {
+	s.mu.Lock()
+	n := c.suppressed
+	c.suppressed = 0
+	if s.counters[key] == c {
+		// Nothing was logged since the interval ended.
+		delete(s.counters, key)
+	}
+	s.mu.Unlock()
+
+	if n == 0 {
+		return
+	}
+	e := &entry{
+		time:  time.Now(),
+		level: key.level,
+		pc:    key.pc,
+		msg:   fmt.Sprintf("suppressed %d similar messages in %v", n, interval),
+	}
+	if key.tmpl != "" {
+		e.keys, e.values = []string{"template"}, []string{key.tmpl}
+	}
+	logger.log(e)
+}
+
+// allowed reports whether a line of the level and template is written for
+// the caller of the function calling allowed, by the levels and the sampling
+// of ctx, which may be nil. It is called before formatting the line.
+func allowed(ctx context.Context, level Level, tmpl string) bool {
+	r := levels.Load()
+	conf := samplingOf(ctx)
+	if conf == nil || level >= FatalLevel {
+		if level < r.min || level >= r.max {
+			return r.enabled(0, level)
+		}
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	if !r.enabled(pcs[0], level) {
+		return false
+	}
+	if conf == nil || level >= FatalLevel {
+		return true
+	}
+	return sampler.allow(sampleKey{pc: pcs[0], tmpl: tmpl, level: level}, conf)
+}
@@ -0,0 +1,87 @@
+package lg
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSampled(t *testing.T) {
+	buf := &syncBuffer{}
+	SetDefaultLoggerOutput(buf, buf)
+	defer SetDefaultLoggerOutput(os.Stdout, os.Stderr)
+
+	ctx := Sampled(context.Background(), Sampling{First: 2, Thereafter: 3, Interval: 50 * time.Millisecond})
+	for i := 0; i < 10; i++ {
+		Errorc(ctx, "blpop err: %v", i)
+	}
+	Errorc(ctx, "other")
+
+	var written []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		written = append(written, line[strings.LastIndex(line, " ")+1:])
+	}
+	if got, want := strings.Join(written, ","), "0,1,4,7,other"; got != want {
+		t.Errorf("written = %v, want %v", got, want)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if out := buf.String(); !strings.Contains(out, "suppressed 6 similar messages in 50ms") ||
+		!strings.Contains(out, `template="blpop err: %v"`) {
+		t.Errorf("missing summary in %q", out)
+	}
+
+	// A new interval starts over.
+	Errorc(ctx, "blpop err: %v", 10)
+	if !strings.HasSuffix(buf.String(), "blpop err: 10\n") {
+		t.Errorf("expect the line of the new interval to be written")
+	}
+}
+
+func TestSampledSweep(t *testing.T) {
+	buf := &syncBuffer{}
+	SetDefaultLoggerOutput(buf, buf)
+	defer SetDefaultLoggerOutput(os.Stdout, os.Stderr)
+
+	ctx := Sampled(context.Background(), Sampling{First: 2, Thereafter: 0, Interval: 20 * time.Millisecond})
+	for i := 0; i < 100; i++ {
+		Infoc(ctx, "request %d done", i)
+		InfoKV(ctx, "request "+strconv.Itoa(i))
+	}
+	time.Sleep(40 * time.Millisecond)
+	Infoc(ctx, "after")
+
+	sampler.mu.Lock()
+	n := 0
+	for key := range sampler.counters {
+		if strings.HasPrefix(key.tmpl, "request") {
+			n++
+		}
+	}
+	sampler.mu.Unlock()
+	if n > 0 {
+		t.Errorf("%d counters kept after their interval", n)
+	}
+}
@@ -0,0 +1,186 @@
+package lg
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Sink receives the formatted log lines besides the outputs of the logger.
+// A sink must not keep line after WriteLine returns. Sinks with buffered
+// lines can implement Flush() error, which is called by Logger.Flush.
+type Sink interface {
+	WriteLine(level Level, line []byte) error
+	Close() error
+}
+
+type flusher interface {
+	Flush() error
+}
+
+type sinkEntry struct {
+	sink   Sink
+	level  Level
+	format Format
+	// errors is the counter of the logger.
+	errors *atomic.Uint64
+
+	// mu serializes the lines written to the sink.
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// write formats the entry for the sink, and writes it or queues it in async.
+func (s *sinkEntry) write(e *entry, async *asyncWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf.Reset()
+	formatEntry(&s.buf, e, s.format, false)
+	w := sinkWriter{entry: s, level: e.level}
+	if async != nil {
+		async.write(w, e.level, s.buf.Bytes())
+		return
+	}
+	w.Write(s.buf.Bytes())
+}
+
+// sinkWriter passes a line to the sink, counting the failures.
+type sinkWriter struct {
+	entry *sinkEntry
+	level Level
+}
+
+func (w sinkWriter) Write(p []byte) (int, error) {
+	if err := w.entry.sink.WriteLine(w.level, p); err != nil {
+		w.entry.errors.Add(1)
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// AddSink fans the lines of the level and above out to s, in the format,
+// which is never colored. The lines are still written to the outputs of
+// the logger, which can be set to io.Discard to only use the sinks.
+func (l *Logger) AddSink(s Sink, level Level, format Format) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sinks = append(l.sinks, &sinkEntry{sink: s, level: level, format: format, errors: &l.sinkErrors})
+}
+
+// SinkErrors returns the count of the lines the sinks failed to write,
+// e.g. while a syslog server is down.
+func (l *Logger) SinkErrors() uint64 {
+	return l.sinkErrors.Load()
+}
+
+// CloseSinks writes the buffered lines, then closes and removes the sinks.
+func (l *Logger) CloseSinks() error {
+	l.Flush()
+
+	l.mu.Lock()
+	sinks := l.sinks
+	l.sinks = nil
+	l.mu.Unlock()
+
+	var err error
+	for _, s := range sinks {
+		if e := s.sink.Close(); e != nil {
+			err = multierror.Append(err, e)
+		}
+	}
+	return err
+}
+
+func (l *Logger) flushSinks() {
+	l.mu.Lock()
+	sinks := l.sinks
+	l.mu.Unlock()
+
+	for _, s := range sinks {
+		if f, ok := s.sink.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				l.output(2, ErrorLevel, "flush log sink: "+err.Error(), nil)
+			}
+		}
+	}
+}
+
+type writerSink struct {
+	w io.Writer
+}
+
+// NewWriterSink returns a sink writing the lines to w, which is closed by
+// Close if it is an io.Closer.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{w: w}
+}
+
+func (s *writerSink) WriteLine(_ Level, line []byte) error {
+	_, err := s.w.Write(line)
+	return err
+}
+
+func (s *writerSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// NewFileSink returns a sink writing to a file rotated by lumberjack,
+// maxSize in megabytes and maxAge in days like WithFileOption.
+func NewFileSink(filename string, maxSize, maxBackup, maxAge int, compress bool) Sink {
+	return NewWriterSink(&lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackup,
+		MaxAge:     maxAge,
+		Compress:   compress,
+	})
+}
+
+type errorSplitSink struct {
+	info   Sink
+	errors Sink
+}
+
+// NewErrorSplitSink returns a sink writing the error and fatal lines to
+// errors and the others to info, e.g. two files of NewFileSink.
+func NewErrorSplitSink(info, errors Sink) Sink {
+	return &errorSplitSink{info: info, errors: errors}
+}
+
+func (s *errorSplitSink) WriteLine(level Level, line []byte) error {
+	if level >= ErrorLevel {
+		return s.errors.WriteLine(level, line)
+	}
+	return s.info.WriteLine(level, line)
+}
+
+func (s *errorSplitSink) Flush() error {
+	var err error
+	for _, sink := range []Sink{s.info, s.errors} {
+		if f, ok := sink.(flusher); ok {
+			if e := f.Flush(); e != nil {
+				err = multierror.Append(err, e)
+			}
+		}
+	}
+	return err
+}
+
+func (s *errorSplitSink) Close() error {
+	var err error
+	for _, sink := range []Sink{s.info, s.errors} {
+		if e := sink.Close(); e != nil {
+			err = multierror.Append(err, e)
+		}
+	}
+	return err
+}
@@ -0,0 +1,260 @@
+package lg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordSink struct {
+	mu     sync.Mutex
+	lines  []string
+	closed bool
+}
+
+func (s *recordSink) WriteLine(_ Level, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, strings.TrimSpace(string(line)))
+	return nil
+}
+
+func (s *recordSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestLoggerSinks(t *testing.T) {
+	l := New()
+	l.SetLoggerOutput(io.Discard, io.Discard)
+	all, errs := &recordSink{}, &recordSink{}
+	l.AddSink(all, DebugLevel, FormatJSON)
+	l.AddSink(errs, ErrorLevel, FormatText)
+
+	l.output(1, DebugLevel, "debug line", nil)
+	l.output(1, ErrorLevel, "error line", nil)
+
+	if len(all.lines) != 2 || !strings.HasPrefix(all.lines[0], "{") {
+		t.Errorf("json sink lines = %q", all.lines)
+	}
+	if len(errs.lines) != 1 || !strings.Contains(errs.lines[0], "error line") || strings.HasPrefix(errs.lines[0], "{") {
+		t.Errorf("text sink lines = %q", errs.lines)
+	}
+
+	if err := l.CloseSinks(); err != nil {
+		t.Fatal(err)
+	}
+	if !all.closed || !errs.closed {
+		t.Error("sinks not closed")
+	}
+	l.output(1, ErrorLevel, "after close", nil)
+	if len(errs.lines) != 1 {
+		t.Errorf("line written to a closed sink: %q", errs.lines)
+	}
+}
+
+// blockingSink blocks the lines until release is closed, then fails them.
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s *blockingSink) WriteLine(_ Level, _ []byte) error {
+	<-s.release
+	return errors.New("sink down")
+}
+
+func (s *blockingSink) Close() error {
+	return nil
+}
+
+func TestLoggerSlowSink(t *testing.T) {
+	l := New()
+	out := &syncBuffer{}
+	l.SetLoggerOutput(out, out)
+	sink := &blockingSink{release: make(chan struct{})}
+	l.AddSink(sink, InfoLevel, FormatText)
+
+	var wg sync.WaitGroup
+	for _, msg := range []string{"one", "two"} {
+		wg.Add(1)
+		go func(msg string) {
+			defer wg.Done()
+			l.output(1, InfoLevel, msg, nil)
+		}(msg)
+	}
+	for i := 0; i < 100 && strings.Count(out.String(), "\n") < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := strings.Count(out.String(), "\n"); n != 2 {
+		t.Errorf("%d lines written to the output while the sink blocks", n)
+	}
+
+	close(sink.release)
+	wg.Wait()
+	if n := l.SinkErrors(); n != 2 {
+		t.Errorf("sink errors = %d, want 2", n)
+	}
+}
+
+func TestErrorSplitSink(t *testing.T) {
+	var info, errs bytes.Buffer
+	s := NewErrorSplitSink(NewWriterSink(&info), NewWriterSink(&errs))
+	for _, level := range []Level{DebugLevel, WarnLevel, ErrorLevel, FatalLevel} {
+		s.WriteLine(level, []byte(level.String()+"\n"))
+	}
+	if got := info.String(); got != "debug\nwarn\n" {
+		t.Errorf("info = %q", got)
+	}
+	if got := errs.String(); got != "error\nfatal\n" {
+		t.Errorf("errors = %q", got)
+	}
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	s, err := NewSyslogSink("udp", pc.LocalAddr().String(), "app", WithSyslogHostname("host"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.WriteLine(InfoLevel, []byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<14>1 ") || !strings.Contains(msg, " host app ") || !strings.HasSuffix(msg, " - - hello") {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s, err := NewSyslogSink("tcp", ln.Addr().String(), "app", WithSyslogFacility(FacilityLocal0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s.WriteLine(ErrorLevel, []byte("one\n"))
+	s.WriteLine(WarnLevel, []byte("two\n"))
+
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []struct {
+		pri  string
+		text string
+	}{{"<131>1 ", "one"}, {"<132>1 ", "two"}} {
+		size, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil {
+			t.Fatalf("bad frame size %q", size)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(msg), want.pri) || !strings.HasSuffix(string(msg), want.text) {
+			t.Errorf("message = %q", msg)
+		}
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		bodies  []string
+		attempt int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempt++
+		if attempt == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(srv.URL, WithHTTPBatch(2, time.Hour), WithHTTPRetry(2, time.Millisecond))
+	for _, line := range []string{"a", "b", "c"} {
+		s.WriteLine(InfoLevel, []byte(line+"\n"))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(bodies, "|") != "a\nb\n|c\n" {
+		t.Errorf("bodies = %q", bodies)
+	}
+	if s.Dropped() != 0 {
+		t.Errorf("dropped %d lines", s.Dropped())
+	}
+}
+
+func TestSyslogSinkReconnectBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSyslogSink("tcp", ln.Addr().String(), "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	ln.Close()
+
+	// The first writes may be buffered before the broken connection is seen.
+	for i := 0; i < 100; i++ {
+		if err := s.WriteLine(InfoLevel, []byte("lost\n")); err != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	err = s.WriteLine(InfoLevel, []byte("lost\n"))
+	if err == nil || !strings.Contains(err.Error(), "reconnect in") {
+		t.Errorf("write while down = %v, want the backoff error", err)
+	}
+}
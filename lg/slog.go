@@ -0,0 +1,168 @@
+package lg
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const (
+	// LevelTrace is the slog level of the lines written by Trace.
+	LevelTrace = slog.LevelDebug - 4
+	// LevelFatal is the slog level of the lines written by Fatal.
+	LevelFatal = slog.LevelError + 4
+)
+
+// SlogHandler is a slog.Handler writing the records with lg, so that
+// slog and lg produce one stream. The attrs of a record are written like
+// the `key=%v` pairs of lg.With, and the LogContext of the context passed
+// to the slog methods ending with Context is kept.
+// example:
+//
+//	slog.SetDefault(slog.New(lg.NewSlogHandler()))
+//	ctx = lg.With(ctx, "traceId=%v", traceId)
+//	slog.InfoContext(ctx, "user login", "user", name)
+type SlogHandler struct {
+	logger *Logger
+	// prefix is the names of the open groups joined by dot.
+	prefix string
+	keys   []string
+	values []string
+}
+
+// NewSlogHandler returns a slog.Handler writing with the default logger.
+func NewSlogHandler() *SlogHandler {
+	return logger.SlogHandler()
+}
+
+// SlogHandler returns a slog.Handler writing with l.
+func (l *Logger) SlogHandler() *SlogHandler {
+	return &SlogHandler{logger: l}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	// The level of the package is only known by the pc of the record.
+	return levelFromSlog(level) >= levels.Load().min
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	level := levelFromSlog(r.Level)
+	if !levels.Load().enabled(r.PC, level) {
+		return nil
+	}
+	if conf := samplingOf(ctx); conf != nil && !sampler.allow(sampleKey{pc: r.PC, tmpl: r.Message, level: level}, conf) {
+		return nil
+	}
+
+	e := &entry{
+		time:  r.Time,
+		level: level,
+		pc:    r.PC,
+		msg:   r.Message,
+	}
+
+	keys, values := h.keys, h.values
+	if lc := ParseFromContext(ctx); lc != nil {
+		e.msg = strings.TrimSpace(strings.Join(lc.msg, " ") + " " + r.Message)
+		keys = append(sliceClone(lc.keys), keys...)
+		values = append(sliceClone(lc.values), values...)
+	}
+	keys, values = sliceClone(keys), sliceClone(values)
+	r.Attrs(func(a slog.Attr) bool {
+		keys, values = appendSlogAttr(keys, values, h.prefix, a)
+		return true
+	})
+	e.keys, e.values = keys, values
+
+	h.logger.log(e)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := *h
+	clone.keys, clone.values = sliceClone(h.keys), sliceClone(h.values)
+	for _, a := range attrs {
+		clone.keys, clone.values = appendSlogAttr(clone.keys, clone.values, h.prefix, a)
+	}
+	return &clone
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// appendSlogAttr flattens a into the keys and values, the keys in
+// a group being prefixed with the group name, e.g. req.method.
+func appendSlogAttr(keys, values []string, prefix string, a slog.Attr) ([]string, []string) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return keys, values
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			keys, values = appendSlogAttr(keys, values, prefix, ga)
+		}
+		return keys, values
+	}
+	return append(keys, prefix+a.Key), append(values, fmt.Sprintf("%v", a.Value.Any()))
+}
+
+// handleSlog passes the entry to h as a record whose attrs are the LogContext keys.
+func handleSlog(h slog.Handler, e *entry) {
+	ctx := context.Background()
+	level := slogLevel(e.level)
+	if !h.Enabled(ctx, level) {
+		return
+	}
+
+	r := slog.NewRecord(e.time, level, e.msg, e.pc)
+	for i := range e.keys {
+		r.AddAttrs(slog.String(e.keys[i], e.values[i]))
+	}
+	h.Handle(ctx, r)
+}
+
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case TraceLevel:
+		return LevelTrace
+	case DebugLevel:
+		return slog.LevelDebug
+	case InfoLevel:
+		return slog.LevelInfo
+	case WarnLevel:
+		return slog.LevelWarn
+	case ErrorLevel:
+		return slog.LevelError
+	default:
+		return LevelFatal
+	}
+}
+
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return TraceLevel
+	case level < slog.LevelInfo:
+		return DebugLevel
+	case level < slog.LevelWarn:
+		return InfoLevel
+	case level < slog.LevelError:
+		return WarnLevel
+	default:
+		// Fatal exits the process, which a slog record never does.
+		return ErrorLevel
+	}
+}
@@ -0,0 +1,68 @@
+package lg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(WithFormat(FormatJSON))
+	l.SetLoggerOutput(&buf, &buf)
+	sl := slog.New(l.SlogHandler()).With("service", "api").WithGroup("req")
+
+	ctx := With(context.Background(), "handle traceId=%v", "abc")
+	sl.InfoContext(ctx, "done", "method", "GET", slog.Group("user", "id", 1))
+
+	var fields map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatalf("unmarshal %q: %v", buf.String(), err)
+	}
+	want := map[string]string{
+		"level":       "info",
+		"msg":         "handle done",
+		"traceId":     "abc",
+		"service":     "api",
+		"req.method":  "GET",
+		"req.user.id": "1",
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("field %s = %q, want %q", k, fields[k], v)
+		}
+	}
+	if !strings.HasPrefix(fields["caller"], "slog_test.go:") {
+		t.Errorf("caller = %q", fields["caller"])
+	}
+}
+
+func TestLoggerSlogBackend(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(WithSlogHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true})))
+
+	ctx := With(context.Background(), "login user=%v", "bob")
+	lc := ParseFromContext(ctx)
+	l.output(1, WarnLevel, "", lc)
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatalf("unmarshal %q: %v", buf.String(), err)
+	}
+	if fields["level"] != "WARN" || fields["msg"] != "login" || fields["user"] != "bob" {
+		t.Errorf("unexpected record: %v", fields)
+	}
+	source, _ := fields["source"].(map[string]interface{})
+	if file, _ := source["file"].(string); !strings.HasSuffix(file, "slog_test.go") {
+		t.Errorf("source = %v", fields["source"])
+	}
+
+	// Routing a logger through its own handler would loop.
+	l.SetSlogHandler(l.SlogHandler())
+	if l.handler != nil {
+		t.Errorf("expect the own handler to be ignored")
+	}
+}
@@ -0,0 +1,192 @@
+package lg
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	syslogMinBackoff = time.Second
+	syslogMaxBackoff = time.Minute
+)
+
+// Syslog facilities, see RFC5424 section 6.2.1.
+const (
+	FacilityUser   = 1
+	FacilityDaemon = 3
+	FacilityLocal0 = 16
+)
+
+// SyslogSink sends the lines as RFC5424 messages to a syslog server over
+// udp, tcp or a unix socket. Over a stream the messages are framed by
+// octet counting (RFC6587).
+type SyslogSink struct {
+	network  string
+	addr     string
+	facility int
+	hostname string
+	appName  string
+
+	mu   sync.Mutex
+	conn net.Conn
+	buf  bytes.Buffer
+	// retryAt is when the server is dialed again after a failed dial, the
+	// backoff doubling on every failure.
+	retryAt time.Time
+	backoff time.Duration
+}
+
+type SyslogOption func(*SyslogSink)
+
+// WithSyslogFacility sets the facility of the messages, FacilityUser by default.
+func WithSyslogFacility(facility int) SyslogOption {
+	return func(s *SyslogSink) {
+		s.facility = facility
+	}
+}
+
+func WithSyslogHostname(hostname string) SyslogOption {
+	return func(s *SyslogSink) {
+		s.hostname = hostname
+	}
+}
+
+// NewSyslogSink connects to the syslog server, network is one of udp, tcp,
+// unix or unixgram, e.g. NewSyslogSink("unixgram", "/dev/log", "myservice").
+// An empty appName is the name of the executable.
+func NewSyslogSink(network, addr, appName string, opts ...SyslogOption) (*SyslogSink, error) {
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
+	}
+	s := &SyslogSink{
+		network:  network,
+		addr:     addr,
+		facility: FacilityUser,
+		appName:  appName,
+	}
+	s.hostname, _ = os.Hostname()
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) connect() error {
+	conn, err := net.DialTimeout(s.network, s.addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("dial syslog %s %s: %w", s.network, s.addr, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *SyslogSink) isStream() bool {
+	switch s.network {
+	case "udp", "udp4", "udp6", "unixgram":
+		return false
+	}
+	return true
+}
+
+// severity maps the level to the syslog severity.
+func severity(level Level) int {
+	switch level {
+	case TraceLevel, DebugLevel:
+		return 7
+	case InfoLevel:
+		return 6
+	case WarnLevel:
+		return 4
+	case ErrorLevel:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// nilValue returns the RFC5424 NILVALUE for an empty header field.
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (s *SyslogSink) format(level Level, line []byte) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "<%d>1 %s %s %s %d - - ",
+		s.facility*8+severity(level),
+		time.Now().Format("2006-01-02T15:04:05.000000Z07:00"),
+		nilValue(s.hostname),
+		nilValue(s.appName),
+		os.Getpid(),
+	)
+	msg.Write(bytes.TrimRight(line, "\n"))
+
+	if !s.isStream() {
+		return msg.Bytes()
+	}
+	s.buf.Reset()
+	s.buf.WriteString(strconv.Itoa(msg.Len()))
+	s.buf.WriteByte(' ')
+	s.buf.Write(msg.Bytes())
+	return s.buf.Bytes()
+}
+
+// WriteLine sends the line, reconnecting once if the connection is broken.
+// While the server is down, the lines fail without dialing it until the
+// reconnect backoff elapses.
+func (s *SyslogSink) WriteLine(level Level, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg := s.format(level, line)
+	if s.conn != nil {
+		if _, err := s.conn.Write(msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	if err := s.reconnect(); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+func (s *SyslogSink) reconnect() error {
+	now := time.Now()
+	if now.Before(s.retryAt) {
+		return fmt.Errorf("syslog %s %s is down, reconnect in %v", s.network, s.addr, s.retryAt.Sub(now).Round(time.Millisecond))
+	}
+	if err := s.connect(); err != nil {
+		s.backoff = min(max(2*s.backoff, syslogMinBackoff), syslogMaxBackoff)
+		s.retryAt = now.Add(s.backoff)
+		return err
+	}
+	s.backoff = 0
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
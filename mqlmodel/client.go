@@ -40,6 +40,7 @@ func (c *client) dialGorm() (*gorm.DB, error) {
 		c.config.Instance,
 		dialer.WithAuth(c.config.Username, c.config.Password),
 		dialer.WithDBName(c.config.Database),
+		dialer.WithLogger(dialer.NewGormLogger(dialer.WithGormInstance(c.config.GetInstanceKey()))),
 	)
 }
 
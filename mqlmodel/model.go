@@ -12,11 +12,16 @@ type MqlModel interface {
 	TableName() string
 }
 
+// AuthConf is the config of a mysql database.
+//
+// The Password supports file:// and env:// references, resolved when the
+// config is read. Unlike a flags.Secret, the file is not read again when it
+// changes: the clients keep the password they were dialed with.
 type AuthConf struct {
 	Instance string
 	Database string
 	Username string
-	Password string
+	Password string `secret:"true"`
 }
 
 func (auth AuthConf) GetInstanceKey() string {
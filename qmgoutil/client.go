@@ -21,10 +21,15 @@ var (
 	clientCache = make(map[string]*Client)
 )
 
+// Config is the config of a mongo client.
+//
+// The Password supports file:// and env:// references, resolved when the
+// config is read. Unlike a flags.Secret, the file is not read again when it
+// changes: the clients keep the password they were dialed with.
 type Config struct {
 	Address     string
 	Username    string
-	Password    string
+	Password    string `secret:"true"`
 	AuthSource  string
 	MaxPoolSize *uint64
 }
@@ -57,7 +62,7 @@ func (c *Client) dial() {
 	c.adds = []string{c.conf.Address}
 	c.uri = c.assembleUri()
 	if qmgoClient, err := c.newQmgoClient(); err != nil {
-		panic(fmt.Errorf("QMongoCli: newQmgoClient err, uri:%s cause:%v", c.uri, err))
+		panic(fmt.Errorf("QMongoCli: newQmgoClient err, uri:%s cause:%v", redactUri(c.uri), err))
 	} else {
 		if c.cli != nil {
 			c.cli.Close(context.Background())
@@ -104,10 +109,19 @@ func (c *Client) assembleUri() string {
 	uri.RawQuery = query.Encode()
 	uriStr := uri.String()
 	uriStr = strings.Replace(uriStr, "?", "/?", 1)
-	lg.Debugf("QMongoCli: mongodb uri -> %s", uriStr)
+	lg.Debugf("QMongoCli: mongodb uri -> %s", redactUri(uriStr))
 	return uriStr
 }
 
+// redactUri hides the password in the uri, so that it can be logged.
+func redactUri(uriStr string) string {
+	uri, err := url.Parse(uriStr)
+	if err != nil {
+		return uriStr
+	}
+	return uri.Redacted()
+}
+
 type NewClientOption func(*Config)
 
 func WithAuth(user, password string) NewClientOption {
@@ -2,11 +2,11 @@ package redisutils
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
 
@@ -15,14 +15,30 @@ const (
 )
 
 type RedisClient struct {
-	pool *redis.Pool
-	ttl  time.Duration
+	pool  *redis.Pool
+	ttl   time.Duration
+	codec Codec
 }
 
-func NewRedisClient(pool *redis.Pool) *RedisClient {
-	return &RedisClient{
-		pool: pool,
+type RedisClientOption func(*RedisClient)
+
+// WithCodec sets the codec of the values of Set and Get, JSONCodec by
+// default. A FramedCodec allows changing it later.
+func WithCodec(c Codec) RedisClientOption {
+	return func(rc *RedisClient) {
+		rc.codec = c
+	}
+}
+
+func NewRedisClient(pool *redis.Pool, opts ...RedisClientOption) *RedisClient {
+	rc := &RedisClient{
+		pool:  pool,
+		codec: JSONCodec{},
+	}
+	for _, opt := range opts {
+		opt(rc)
 	}
+	return rc
 }
 
 func (rc *RedisClient) GetConn() redis.Conn {
@@ -88,7 +104,7 @@ func (rc *RedisClient) TransactionPipeline(conn redis.Conn, watchKey []string, c
 }
 
 func (rc *RedisClient) SetWithTTL(key string, value any, ttl time.Duration) error {
-	data, err := json.Marshal(value)
+	data, err := rc.codec.Marshal(value)
 	if err != nil {
 		return errors.Wrap(err, "encode")
 	}
@@ -112,7 +128,7 @@ func (rc *RedisClient) Get(key string, out any) error {
 		return errors.Wrap(err, "redis.GET")
 	}
 
-	if err := json.Unmarshal(data, &out); err != nil {
+	if err := rc.codec.Unmarshal(data, out); err != nil {
 		return errors.Wrap(err, "decode")
 	}
 
@@ -164,6 +180,39 @@ func (rc *RedisClient) UnLock(key string) (err error) {
 	return rc.Delete(key)
 }
 
+var unlockScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// LockWithToken is like Lock, but the lock holds a random token which must
+// be given to UnLockWithToken. It is safe against releasing the lock of
+// another owner once this one expired, which UnLock does not check.
+func (rc *RedisClient) LockWithToken(key string, expire time.Duration) (token string, err error) {
+	token = uuid.NewString()
+	_, err = redis.String(rc.Do("SET", key, token, "PX", expire.Milliseconds(), "NX"))
+	if err == redis.ErrNil {
+		return "", ErrLockFailed
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// UnLockWithToken releases the lock of key if it still holds token.
+func (rc *RedisClient) UnLockWithToken(key, token string) error {
+	conn := rc.GetConn()
+	defer conn.Close()
+
+	_, err := unlockScript.Do(conn, key, token)
+	return err
+}
+
 func (rc *RedisClient) Close() error {
 	return rc.pool.Close()
 }
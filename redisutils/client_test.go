@@ -54,3 +54,30 @@ func TestClientLock(t *testing.T) {
 
 	fmt.Println("after lockB")
 }
+
+func TestClientLockWithToken(t *testing.T) {
+	client := NewRedisClient(dialer.DialRedisPool("localhost:6379", 12, 100))
+	defer client.Delete("testTokenLock")
+
+	token, err := client.LockWithToken("testTokenLock", time.Second)
+	if err != nil {
+		t.Fatalf("lock error: %v", err)
+	}
+	if _, err := client.LockWithToken("testTokenLock", time.Second); err != ErrLockFailed {
+		t.Errorf("second lock error = %v, want ErrLockFailed", err)
+	}
+
+	if err := client.UnLockWithToken("testTokenLock", "other"); err != nil {
+		t.Fatalf("unlock error: %v", err)
+	}
+	if _, err := client.LockWithToken("testTokenLock", time.Second); err != ErrLockFailed {
+		t.Errorf("lock released with a wrong token")
+	}
+
+	if err := client.UnLockWithToken("testTokenLock", token); err != nil {
+		t.Fatalf("unlock error: %v", err)
+	}
+	if _, err := client.LockWithToken("testTokenLock", time.Second); err != nil {
+		t.Errorf("lock after unlock error: %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package redisutils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// Codec encodes the values stored in redis.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default codec, compatible with the values stored before
+// the codecs.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// MsgpackCodec encodes the values like TaskQueue does.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// GobCodec encodes the values with encoding/gob. The concrete types stored in
+// interfaces must be registered with gob.Register.
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ProtobufCodec encodes the proto.Message values. It decodes into a message,
+// or a pointer to a message pointer which is allocated.
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().Kind() == reflect.Pointer {
+		elem := reflect.New(rv.Elem().Type().Elem())
+		if m, ok := elem.Interface().(proto.Message); ok {
+			if err := proto.Unmarshal(data, m); err != nil {
+				return err
+			}
+			rv.Elem().Set(elem)
+			return nil
+		}
+	}
+	return errors.Errorf("protobuf: %T is not a proto.Message", v)
+}
+
+// Compression is the compression of the values framed with a header.
+type Compression byte
+
+const (
+	NoCompression Compression = iota
+	Gzip
+	Snappy
+)
+
+func (c Compression) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case Gzip:
+		return "gzip"
+	case Snappy:
+		return "snappy"
+	default:
+		return "unknown"
+	}
+}
+
+func (c Compression) compress(data []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case Gzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Snappy:
+		return snappy.Encode(nil, data), nil
+	default:
+		return nil, errors.Errorf("unknown compression %d", c)
+	}
+}
+
+func (c Compression) decompress(data []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case Snappy:
+		return snappy.Decode(nil, data)
+	default:
+		return nil, errors.Errorf("unknown compression %d", c)
+	}
+}
+
+// The header of a framed value is the magic prefix, followed by a byte of
+// the compression in the high 4 bits and the codec id in the low 4 bits. The
+// prefix starts with 0xc1, which is never used by msgpack, is an invalid
+// length for gob and is not ASCII like JSON, so that the values of these
+// codecs are read as unframed values.
+const (
+	maxCodecID     = 0x0f
+	maxCompression = 0x0f
+)
+
+var frameMagic = []byte{0xc1, 'g', 'u'}
+
+var (
+	codecsMu   sync.RWMutex
+	codecsByID = map[byte]Codec{
+		1: JSONCodec{},
+		2: MsgpackCodec{},
+		3: GobCodec{},
+		4: ProtobufCodec{},
+	}
+	codecIDs = map[reflect.Type]byte{
+		reflect.TypeOf(JSONCodec{}):     1,
+		reflect.TypeOf(MsgpackCodec{}):  2,
+		reflect.TypeOf(GobCodec{}):      3,
+		reflect.TypeOf(ProtobufCodec{}): 4,
+	}
+)
+
+// RegisterCodec sets the id written in the header of the values framed with
+// a codec of the type of c, from 5 to 15, 1 to 4 being the builtin ones.
+func RegisterCodec(id byte, c Codec) {
+	if id == 0 || id > maxCodecID {
+		panic(errors.Errorf("redisutils: codec id %d out of range", id))
+	}
+
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if _, ok := codecsByID[id]; ok {
+		panic(errors.Errorf("redisutils: codec id %d registered twice", id))
+	}
+	codecsByID[id] = c
+	codecIDs[reflect.TypeOf(c)] = id
+}
+
+func codecID(c Codec) (byte, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	id, ok := codecIDs[reflect.TypeOf(c)]
+	return id, ok
+}
+
+func codecOf(id byte) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecsByID[id]
+	return c, ok
+}
+
+// Frame returns data, encoded by c, after a header naming c and compressed
+// if it has at least minSize bytes.
+func Frame(c Codec, compression Compression, minSize int, data []byte) ([]byte, error) {
+	id, ok := codecID(c)
+	if !ok {
+		return nil, errors.Errorf("codec %T is not registered", c)
+	}
+	if compression > maxCompression {
+		return nil, errors.Errorf("unknown compression %d", compression)
+	}
+	if len(data) < minSize {
+		compression = NoCompression
+	}
+
+	body, err := compression.compress(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "compress")
+	}
+	framed := make([]byte, 0, len(frameMagic)+1+len(body))
+	framed = append(framed, frameMagic...)
+	framed = append(framed, byte(compression)<<4|id)
+	return append(framed, body...), nil
+}
+
+// Unframe returns the codec and the decompressed data of a value written by
+// Frame, and ok false with data as it is if it has no header.
+func Unframe(data []byte) (c Codec, body []byte, ok bool, err error) {
+	if len(data) <= len(frameMagic) || !bytes.HasPrefix(data, frameMagic) {
+		return nil, data, false, nil
+	}
+
+	header := data[len(frameMagic)]
+	compression := Compression(header >> 4 & maxCompression)
+	c, ok = codecOf(header & maxCodecID)
+	if !ok {
+		return nil, nil, false, errors.Errorf("unknown codec id %d", header&maxCodecID)
+	}
+	body, err = compression.decompress(data[len(frameMagic)+1:])
+	if err != nil {
+		return nil, nil, false, errors.Wrap(err, "decompress")
+	}
+	return c, body, true, nil
+}
+
+// FramedCodec writes the values with a codec after a header naming it,
+// and reads the values of any registered codec, so that the codec of the
+// values can be changed without flushing them. The values without header
+// are read with the legacy codec.
+type FramedCodec struct {
+	codec       Codec
+	legacy      Codec
+	compression Compression
+	minSize     int
+}
+
+type FramedCodecOption func(*FramedCodec)
+
+// WithCompression compresses the values of at least minSize bytes.
+func WithCompression(compression Compression, minSize int) FramedCodecOption {
+	return func(f *FramedCodec) {
+		f.compression = compression
+		f.minSize = minSize
+	}
+}
+
+// WithLegacyCodec sets the codec of the values without header, JSONCodec by
+// default. It is told apart from the header for the JSON, msgpack and gob
+// values.
+func WithLegacyCodec(c Codec) FramedCodecOption {
+	return func(f *FramedCodec) {
+		f.legacy = c
+	}
+}
+
+// NewFramedCodec returns a FramedCodec writing with c, which must be a
+// builtin codec or registered with RegisterCodec.
+func NewFramedCodec(c Codec, opts ...FramedCodecOption) *FramedCodec {
+	f := &FramedCodec{
+		codec:  c,
+		legacy: JSONCodec{},
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+func (f *FramedCodec) Marshal(v any) ([]byte, error) {
+	data, err := f.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Frame(f.codec, f.compression, f.minSize, data)
+}
+
+func (f *FramedCodec) Unmarshal(data []byte, v any) error {
+	c, body, ok, err := Unframe(data)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		c = f.legacy
+	}
+	return c.Unmarshal(body, v)
+}
@@ -0,0 +1,122 @@
+package redisutils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/superwhys/goutils/dialer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type codecValue struct {
+	Name  string
+	Count int
+}
+
+func TestCodecs(t *testing.T) {
+	for _, c := range []Codec{JSONCodec{}, MsgpackCodec{}, GobCodec{}, NewFramedCodec(MsgpackCodec{}, WithCompression(Gzip, 0))} {
+		data, err := c.Marshal(codecValue{Name: "a", Count: 2})
+		if err != nil {
+			t.Fatalf("%T: marshal: %v", c, err)
+		}
+		var out codecValue
+		if err := c.Unmarshal(data, &out); err != nil || out != (codecValue{Name: "a", Count: 2}) {
+			t.Errorf("%T: unmarshal = %+v, %v", c, out, err)
+		}
+	}
+
+	data, err := ProtobufCodec{}.Marshal(wrapperspb.String("proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg *wrapperspb.StringValue
+	if err := (ProtobufCodec{}).Unmarshal(data, &msg); err != nil || msg.GetValue() != "proto" {
+		t.Errorf("protobuf unmarshal = %v, %v", msg, err)
+	}
+	if _, err := (ProtobufCodec{}).Marshal(codecValue{}); err == nil {
+		t.Errorf("protobuf encoded a non message")
+	}
+}
+
+func TestFrame(t *testing.T) {
+	data := bytes.Repeat([]byte("value "), 100)
+	for _, compression := range []Compression{NoCompression, Gzip, Snappy} {
+		framed, err := Frame(MsgpackCodec{}, compression, 64, data)
+		if err != nil {
+			t.Fatalf("%v: %v", compression, err)
+		}
+		if compression != NoCompression && len(framed) >= len(data) {
+			t.Errorf("%v: %d bytes not compressed", compression, len(framed))
+		}
+		c, body, ok, err := Unframe(framed)
+		if err != nil || !ok || !bytes.Equal(body, data) {
+			t.Fatalf("%v: unframe = %v, %v", compression, ok, err)
+		}
+		if _, isMsgpack := c.(MsgpackCodec); !isMsgpack {
+			t.Errorf("%v: codec = %T", compression, c)
+		}
+	}
+
+	// The values below the threshold are not compressed.
+	framed, _ := Frame(JSONCodec{}, Gzip, 64, []byte(`"short"`))
+	if Compression(framed[len(frameMagic)]>>4) != NoCompression {
+		t.Errorf("short value compressed")
+	}
+
+	for _, c := range []Codec{JSONCodec{}, MsgpackCodec{}, GobCodec{}} {
+		data, _ := c.Marshal(codecValue{Name: "a", Count: 1})
+		if _, body, ok, _ := Unframe(data); ok || !bytes.Equal(body, data) {
+			t.Errorf("%T value read as a framed value", c)
+		}
+	}
+}
+
+func TestFramedCodecMigration(t *testing.T) {
+	old := NewRedisClient(dialer.DialRedisPool("localhost:6379", 12, 100))
+	client := NewRedisClient(dialer.DialRedisPool("localhost:6379", 12, 100),
+		WithCodec(NewFramedCodec(GobCodec{}, WithCompression(Snappy, 16))))
+	defer client.Delete("codec_legacy")
+	defer client.Delete("codec_new")
+
+	if err := old.Set("codec_legacy", codecValue{Name: "json", Count: 1}); err != nil {
+		t.Fatal(err)
+	}
+	var out codecValue
+	if err := client.Get("codec_legacy", &out); err != nil || out.Name != "json" {
+		t.Errorf("legacy value = %+v, %v", out, err)
+	}
+
+	long := codecValue{Name: strings.Repeat("gob", 100), Count: 2}
+	if err := client.Set("codec_new", long); err != nil {
+		t.Fatal(err)
+	}
+	out = codecValue{}
+	if err := client.Get("codec_new", &out); err != nil || out != long {
+		t.Errorf("framed value = %+v, %v", out, err)
+	}
+}
+
+func TestFramedCodecMsgpackMigration(t *testing.T) {
+	old := NewRedisClient(dialer.DialRedisPool("localhost:6379", 12, 100), WithCodec(MsgpackCodec{}))
+	client := NewRedisClient(dialer.DialRedisPool("localhost:6379", 12, 100),
+		WithCodec(NewFramedCodec(JSONCodec{}, WithLegacyCodec(MsgpackCodec{}))))
+	defer client.Delete("codec_msgpack")
+
+	// A struct is a msgpack map, whose first byte has its high bit set.
+	if err := old.Set("codec_msgpack", codecValue{Name: "msgpack", Count: 3}); err != nil {
+		t.Fatal(err)
+	}
+	var out codecValue
+	if err := client.Get("codec_msgpack", &out); err != nil || out != (codecValue{Name: "msgpack", Count: 3}) {
+		t.Errorf("legacy msgpack value = %+v, %v", out, err)
+	}
+
+	if err := client.Set("codec_msgpack", codecValue{Name: "json", Count: 4}); err != nil {
+		t.Fatal(err)
+	}
+	out = codecValue{}
+	if err := client.Get("codec_msgpack", &out); err != nil || out != (codecValue{Name: "json", Count: 4}) {
+		t.Errorf("framed value = %+v, %v", out, err)
+	}
+}
@@ -1,17 +1,17 @@
 package redisutils
 
 import (
+	"github.com/gomodule/redigo/redis"
 	"github.com/superwhys/goutils/dialer"
 	"github.com/superwhys/goutils/flags"
-	"github.com/superwhys/goutils/lg"
 	"github.com/superwhys/goutils/slowinit"
 )
 
 type RedisConf struct {
-	Server   string `desc:"redis server name (default localhost:6379)"`
-	Password string `desc:"redis server password"`
-	Db       int    `desc:"redis db (default 0)"`
-	MaxIdle  int    `desc:"redis maxidle (default 100)"`
+	Server   string       `desc:"redis server name (default localhost:6379)"`
+	Password flags.Secret `desc:"redis server password, support file:// and env:// reference"`
+	Db       int          `desc:"redis db (default 0)"`
+	MaxIdle  int          `desc:"redis maxidle (default 100)"`
 }
 
 func (rc *RedisConf) SetDefault() {
@@ -21,26 +21,26 @@ func (rc *RedisConf) SetDefault() {
 }
 
 var (
-	redisConfFlag = flags.Struct("redisConf", &RedisConf{}, "Redis config")
+	redisConfFlag = flags.StructOf("redisConf", RedisConf{}, "Redis config")
 )
 
 var Client *RedisClient
 
+// dialRedisPool dials the pool of the config. The new connections read the
+// password again, from the secret file when it is rotated.
+func dialRedisPool() *redis.Pool {
+	conf := redisConfFlag()
+	return dialer.DialRedisPoolWithPasswordFunc(
+		conf.Server,
+		conf.Db,
+		conf.MaxIdle,
+		func() string { return redisConfFlag().Password.Value() },
+	)
+}
+
 func init() {
 	slowinit.RegisterObject("redisClient", func() error {
-		conf := &RedisConf{}
-		lg.PanicError(redisConfFlag(conf))
-
-		var pwd []string
-		if conf.Password != "" {
-			pwd = append(pwd, conf.Password)
-		}
-		Client = NewRedisClient(dialer.DialRedisPool(
-			conf.Server,
-			conf.Db,
-			conf.MaxIdle,
-			pwd...,
-		))
+		Client = NewRedisClient(dialRedisPool())
 		return nil
 	})
 }
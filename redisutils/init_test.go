@@ -0,0 +1,135 @@
+package redisutils
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/superwhys/goutils/flags/flagstest"
+)
+
+func TestRedisConf(t *testing.T) {
+	t.Setenv("TEST_REDIS_PASSWORD", "secret")
+	flagstest.Parse(t, []string{"--redisConf.Db=3"}, map[string]interface{}{
+		"redisConf": map[string]interface{}{
+			"server":   "redis:6380",
+			"password": "env://TEST_REDIS_PASSWORD",
+		},
+	})
+
+	conf := redisConfFlag()
+	if conf.Server != "redis:6380" || conf.Db != 3 || conf.MaxIdle != 100 {
+		t.Errorf("unexpected redis conf: %+v", conf)
+	}
+	if conf.Password.Value() != "secret" {
+		t.Errorf("password: want secret, got %v", conf.Password.Value())
+	}
+}
+
+// authServer replies OK to every command, and records the AUTH passwords.
+type authServer struct {
+	ln        net.Listener
+	mu        sync.Mutex
+	passwords []string
+}
+
+func newAuthServer(t *testing.T) *authServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &authServer{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *authServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		// A command is an array of bulk strings: *N, then $len and the arg N times.
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+			arg, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			args[i] = strings.TrimSpace(arg)
+		}
+		if len(args) == 2 && strings.EqualFold(args[0], "AUTH") {
+			s.mu.Lock()
+			s.passwords = append(s.passwords, args[1])
+			s.mu.Unlock()
+		}
+		conn.Write([]byte("+OK\r\n"))
+	}
+}
+
+func (s *authServer) lastPassword() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.passwords) == 0 {
+		return ""
+	}
+	return s.passwords[len(s.passwords)-1]
+}
+
+func TestRedisPasswordRotation(t *testing.T) {
+	server := newAuthServer(t)
+	file := filepath.Join(t.TempDir(), "redis-password")
+	if err := os.WriteFile(file, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	flagstest.Parse(t, nil, map[string]interface{}{
+		"redisConf": map[string]interface{}{
+			"server":   server.ln.Addr().String(),
+			"password": "file://" + file,
+		},
+	})
+
+	pool := dialRedisPool()
+	defer pool.Close()
+	conn, err := pool.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if got := server.lastPassword(); got != "first" {
+		t.Errorf("password = %q, want first", got)
+	}
+
+	// The secret file is rotated, the new connections use the new password.
+	if err := os.WriteFile(file, []byte("rotated\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	conn, err = pool.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if got := server.lastPassword(); got != "rotated" {
+		t.Errorf("password after rotation = %q, want rotated", got)
+	}
+}
@@ -165,6 +165,8 @@ func (q *TaskQueue) iter(c chan *Task) {
 	}
 	defer conn.Close()
 
+	// The blpop error repeats every few seconds while redis is unreachable.
+	logCtx := lg.Sampled(q.ctx)
 	for {
 		if q.checkCancel() {
 			close(c)
@@ -173,7 +175,7 @@ func (q *TaskQueue) iter(c chan *Task) {
 
 		bulk, err := redis.Values(q.blpop(conn))
 		if err != nil || len(bulk) != 2 {
-			lg.Errorf("queue %v blpop err(maybe timeout): %v", q.name, err)
+			lg.Errorc(logCtx, "queue %v blpop err(maybe timeout): %v", q.name, err)
 			time.Sleep(time.Second * 2)
 			conn.Close()
 			conn, err = q.rc.GetConnWithContext(q.ctx)
@@ -8,8 +8,14 @@ import (
 	"github.com/superwhys/goutils/lg"
 	"github.com/superwhys/goutils/service/finder"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+// DialGrpc dials the service with the default client interceptors, which log
+// the calls and set a 10s deadline to the calls without one. They are set by
+// the client options among opts, e.g.
+//
+//	service.DialGrpc("user", service.WithCallTimeout(3*time.Second), service.WithRetry(3, 100*time.Millisecond))
 func DialGrpc(service string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	return DialGrpcWithTimeOut(10*time.Second, service, opts...)
 }
@@ -22,12 +28,15 @@ func DialGrpcWithTimeOut(timeout time.Duration, service string, opts ...grpc.Dia
 }
 
 func DialGrpcWithContext(ctx context.Context, service string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
-	opts = append(opts, grpc.WithBlock(), grpc.WithInsecure())
 	return dialGrpcWithTagContext(ctx, service, "", opts...)
 }
 
 func dialGrpcWithTagContext(ctx context.Context, service, tag string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
-	options := append(opts, grpc.WithBlock(), grpc.WithInsecure())
+	options := []grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	options = append(options, clientInterceptors(opts)...)
 	options = append(options, opts...)
 
 	address := finder.GetServiceFinder().GetAddressWithTag(service, tag)
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/superwhys/goutils/lg"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const DefaultCallTimeout = 10 * time.Second
+
+type grpcClientConfig struct {
+	callTimeout        time.Duration
+	maxRetries         int
+	retryBackoff       time.Duration
+	budget             *retryBudget
+	idempotent         map[string]bool
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+}
+
+// clientOption is a grpc.DialOption doing nothing to the dial, it configures
+// the client interceptors set by DialGrpc.
+type clientOption struct {
+	grpc.EmptyDialOption
+	apply func(*grpcClientConfig)
+}
+
+// WithCallTimeout sets the deadline of the calls without one, 0 disables it.
+func WithCallTimeout(timeout time.Duration) grpc.DialOption {
+	return clientOption{apply: func(c *grpcClientConfig) {
+		c.callTimeout = timeout
+	}}
+}
+
+// WithRetry retries the unary calls of the idempotent methods failing with
+// codes.Unavailable at most maxRetries times, with a jittered backoff doubled
+// at every retry. The methods are idempotent by WithIdempotentMethods or by
+// the Idempotent call option.
+func WithRetry(maxRetries int, backoff time.Duration) grpc.DialOption {
+	return clientOption{apply: func(c *grpcClientConfig) {
+		c.maxRetries = maxRetries
+		c.retryBackoff = backoff
+	}}
+}
+
+// WithRetryBudget limits the retries when the service keeps failing, like the
+// retry throttling of gRPC: every failure costs a token, every success gives
+// back ratio tokens, and the retries stop while half of maxTokens or less
+// are left. The default budget is 10 tokens with a ratio of 0.1.
+func WithRetryBudget(maxTokens int, ratio float64) grpc.DialOption {
+	return clientOption{apply: func(c *grpcClientConfig) {
+		c.budget = newRetryBudget(maxTokens, ratio)
+	}}
+}
+
+// WithIdempotentMethods marks the full methods, e.g. "/user.UserService/GetUser",
+// as safe to retry.
+func WithIdempotentMethods(methods ...string) grpc.DialOption {
+	return clientOption{apply: func(c *grpcClientConfig) {
+		for _, m := range methods {
+			c.idempotent[m] = true
+		}
+	}}
+}
+
+// WithClientUnaryInterceptors adds interceptors called after the logging one,
+// once for every attempt of a call.
+func WithClientUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) grpc.DialOption {
+	return clientOption{apply: func(c *grpcClientConfig) {
+		c.unaryInterceptors = append(c.unaryInterceptors, interceptors...)
+	}}
+}
+
+func WithClientStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) grpc.DialOption {
+	return clientOption{apply: func(c *grpcClientConfig) {
+		c.streamInterceptors = append(c.streamInterceptors, interceptors...)
+	}}
+}
+
+type idempotentOption struct {
+	grpc.EmptyCallOption
+}
+
+// Idempotent marks a call as safe to retry, e.g.
+//
+//	client.GetUser(ctx, req, service.Idempotent())
+func Idempotent() grpc.CallOption {
+	return idempotentOption{}
+}
+
+// clientInterceptors returns the dial options setting the client interceptors
+// configured by the client options among opts.
+func clientInterceptors(opts []grpc.DialOption) []grpc.DialOption {
+	conf := &grpcClientConfig{
+		callTimeout: DefaultCallTimeout,
+		idempotent:  map[string]bool{},
+	}
+	for _, opt := range opts {
+		if o, ok := opt.(clientOption); ok {
+			o.apply(conf)
+		}
+	}
+	if conf.budget == nil {
+		conf.budget = newRetryBudget(10, 0.1)
+	}
+
+	unary := []grpc.UnaryClientInterceptor{}
+	if conf.callTimeout > 0 {
+		unary = append(unary, timeoutInterceptor(conf.callTimeout))
+	}
+	unary = append(unary, lg.UnaryClientInterceptor)
+	if conf.maxRetries > 0 {
+		unary = append(unary, retryInterceptor(conf))
+	}
+	unary = append(unary, conf.unaryInterceptors...)
+
+	stream := append([]grpc.StreamClientInterceptor{lg.StreamClientInterceptor}, conf.streamInterceptors...)
+
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(stream...),
+	}
+}
+
+func timeoutInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func isIdempotent(conf *grpcClientConfig, method string, opts []grpc.CallOption) bool {
+	if conf.idempotent[method] {
+		return true
+	}
+	for _, opt := range opts {
+		if _, ok := opt.(idempotentOption); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func retryInterceptor(conf *grpcClientConfig) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !isIdempotent(conf, method, opts) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		backoff := conf.retryBackoff
+		for attempt := 0; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if status.Code(err) != codes.Unavailable {
+				conf.budget.success()
+				return err
+			}
+			if !conf.budget.failure() || attempt >= conf.maxRetries {
+				return err
+			}
+
+			wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff)+1))
+			lg.Debugc(ctx, "Retry method %s in %s, attempt=%d err=%v", method, wait, attempt+1, err)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return err
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// retryBudget is the token bucket of WithRetryBudget.
+type retryBudget struct {
+	mu     sync.Mutex
+	tokens float64
+	max    float64
+	ratio  float64
+}
+
+func newRetryBudget(maxTokens int, ratio float64) *retryBudget {
+	return &retryBudget{tokens: float64(maxTokens), max: float64(maxTokens), ratio: ratio}
+}
+
+func (b *retryBudget) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += b.ratio
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+}
+
+// failure takes a token and reports whether a retry is allowed.
+func (b *retryBudget) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens > 0 {
+		b.tokens--
+	}
+	return b.tokens > b.max/2
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryInterceptor(t *testing.T) {
+	conf := &grpcClientConfig{
+		maxRetries:   3,
+		retryBackoff: time.Millisecond,
+		budget:       newRetryBudget(10, 0.1),
+		idempotent:   map[string]bool{"/test.Service/Get": true},
+	}
+	retry := retryInterceptor(conf)
+
+	calls := 0
+	unavailable := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		if calls < 3 {
+			return status.Error(codes.Unavailable, "down")
+		}
+		return nil
+	}
+
+	if err := retry(context.Background(), "/test.Service/Get", nil, nil, nil, unavailable); err != nil {
+		t.Errorf("idempotent call: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("idempotent call made %d attempts, want 3", calls)
+	}
+
+	calls = 0
+	err := retry(context.Background(), "/test.Service/Create", nil, nil, nil, unavailable)
+	if status.Code(err) != codes.Unavailable || calls != 1 {
+		t.Errorf("non idempotent call: err=%v attempts=%d", err, calls)
+	}
+
+	calls = 0
+	if err := retry(context.Background(), "/test.Service/Create", nil, nil, nil, unavailable, Idempotent()); err != nil || calls != 3 {
+		t.Errorf("call with Idempotent: err=%v attempts=%d", err, calls)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	b := newRetryBudget(4, 0.5)
+	allowed := 0
+	for b.failure() {
+		allowed++
+	}
+	if allowed != 1 {
+		t.Errorf("allowed %d retries, want 1", allowed)
+	}
+	for i := 0; i < 4; i++ {
+		b.success()
+	}
+	if !b.failure() {
+		t.Errorf("retries should be allowed after successes")
+	}
+}
@@ -180,6 +180,14 @@ func WithPprof() SuperServiceOption {
 	}
 }
 
+// WithLogLevelHandler serves the log levels at /debug/loglevel,
+// which are changed by PUT or POST with a spec like info,redisutils=debug.
+func WithLogLevelHandler() SuperServiceOption {
+	return func(ys *SuperService) {
+		ys.httpMux.Handle("/debug/loglevel", lg.LevelHandler())
+	}
+}
+
 func WithHttpHandler(pattern string, handler http.Handler) SuperServiceOption {
 	return func(ys *SuperService) {
 		if !strings.HasPrefix(pattern, "/") {
@@ -271,6 +279,7 @@ func (ys *SuperService) waitGraceFulKill(ctx context.Context, listener net.Liste
 		}
 		ys.grpcServer.GracefulStop()
 		lg.Info("Graceful stopped server successfully")
+		lg.Flush()
 
 		return errors.Errorf("Signal: %s", sg.String())
 	case <-ctx.Done():
@@ -499,6 +508,7 @@ func (ys *SuperService) Serve(listener net.Listener) error {
 	}
 
 	ys.displayWelcome(listener)
+	defer lg.Flush()
 	if err := grp.Wait(); err != nil {
 		lg.Error(fmt.Sprintf("error group error: %v", err))
 		return err
@@ -12,6 +12,9 @@ type slowerObject struct {
 
 var (
 	objs = make([]*slowerObject, 0)
+
+	// scopedObjs are only initialized when their scope is, e.g. the subcommand they belong to is selected.
+	scopedObjs = make(map[string][]*slowerObject)
 )
 
 func RegisterObject(name string, fn func() error) {
@@ -21,7 +24,24 @@ func RegisterObject(name string, fn func() error) {
 	})
 }
 
+// RegisterScopedObject registers an object which is initialized by InitScope(scope) instead of Init.
+func RegisterScopedObject(scope, name string, fn func() error) {
+	scopedObjs[scope] = append(scopedObjs[scope], &slowerObject{
+		name: name,
+		fn:   fn,
+	})
+}
+
 func Init() {
+	initObjects(objs)
+}
+
+// InitScope initializes the objects registered to the scope.
+func InitScope(scope string) {
+	initObjects(scopedObjs[scope])
+}
+
+func initObjects(objs []*slowerObject) {
 	for _, obj := range objs {
 		if err := obj.fn(); err != nil {
 			lg.PanicError(errors.Wrapf(err, "slower init obj: %v", obj.name))